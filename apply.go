@@ -0,0 +1,100 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/util/csaupgrade"
+)
+
+// applySecret server-side applies secret to c as the FieldManager.  Pentagon
+// owns only the fields it applies, so keys and labels it applied before but
+// that secret no longer has are removed, while fields set by others are left
+// alone.  If force is set, fields that other field managers changed are
+// taken over instead of conflicting.
+func (r *Reflector) applySecret(ctx context.Context, c *cluster, secret *corev1.Secret, force bool) error {
+	_, err := c.secrets(secret.Namespace).Apply(ctx, secretApplyConfiguration(secret), metav1.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        force,
+		DryRun:       r.dryRunOptions(),
+	})
+	if errors.IsConflict(err) && !force {
+		return fmt.Errorf("%s (set forceConflicts to take ownership of these fields)", err)
+	}
+	return err
+}
+
+// secretApplyConfiguration returns the apply configuration that sets the
+// fields of secret that pentagon manages.
+func secretApplyConfiguration(secret *corev1.Secret) *corev1ac.SecretApplyConfiguration {
+	ac := corev1ac.Secret(secret.Name, secret.Namespace).
+		WithLabels(secret.Labels).
+		WithData(secret.Data)
+	if len(secret.Annotations) > 0 {
+		ac.WithAnnotations(secret.Annotations)
+	}
+	if secret.Type != "" {
+		ac.WithType(secret.Type)
+	}
+	return ac
+}
+
+// managedSecret returns a secret with only the fields of existing that
+// pentagon applied.
+func managedSecret(existing *corev1.Secret) (*corev1.Secret, error) {
+	extracted, err := corev1ac.ExtractSecret(existing, FieldManager)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting fields managed by %s: %s", FieldManager, err)
+	}
+
+	managed := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        existing.Name,
+			Namespace:   existing.Namespace,
+			Labels:      extracted.Labels,
+			Annotations: extracted.Annotations,
+		},
+		Data: extracted.Data,
+	}
+	if extracted.Type != nil {
+		managed.Type = *extracted.Type
+	}
+	return managed, nil
+}
+
+// upgradeManagedFields hands the fields of existing that pentagon wrote with
+// updates, before it used server-side apply, over to its apply field
+// manager.  Otherwise the old entry would keep owning keys that pentagon
+// stops applying, and they'd never be removed.  Those updates were made as
+// the "pentagon" field manager too, since the API server names managers
+// after the client's user agent when none is given.
+func (r *Reflector) upgradeManagedFields(ctx context.Context, c *cluster, existing *corev1.Secret) error {
+	managers := sets.New(FieldManager)
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(existing, managers, FieldManager)
+	if err != nil {
+		return fmt.Errorf("error upgrading managed fields of secret %s: %s", existing.Name, err)
+	}
+	if patch == nil {
+		return nil
+	}
+
+	// upgrade the local copy too, so that it can be compared
+	if err := csaupgrade.UpgradeManagedFields(existing, managers, FieldManager); err != nil {
+		return fmt.Errorf("error upgrading managed fields of secret %s: %s", existing.Name, err)
+	}
+	if r.dryRun {
+		return nil
+	}
+
+	_, err = c.secrets(existing.Namespace).Patch(ctx, existing.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("error upgrading managed fields of secret %s: %s", existing.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package pentagon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestReflectorApplyRemovesKeys(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset()
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+	mapping := Mapping{
+		SourceType:      VaultSourceType,
+		Path:            "secrets/foo",
+		SecretName:      "foo",
+		SecretType:      corev1.SecretTypeOpaque,
+		VaultEngineType: vault.EngineTypeKeyValueV1,
+	}
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	vaultClient.Write("secrets/foo", map[string]any{"a": "1", "b": "2"})
+	if err := r.Reflect(ctx, []Mapping{mapping}); err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	// another field manager adds a key and a label of its own
+	secret, err := secrets.Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	secret.Data["other"] = []byte("x")
+	secret.Labels["team"] = "core"
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{FieldManager: "kubectl-edit"})
+	if err != nil {
+		t.Fatalf("unable to update secret: %s", err)
+	}
+
+	// which pentagon doesn't consider a change
+	results, err := r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUnchanged {
+		t.Fatalf("fields of other managers shouldn't cause an update: %+v", results[0])
+	}
+
+	// removing a key from the source removes it from the secret, leaving the
+	// other manager's fields alone
+	vaultClient.Write("secrets/foo", map[string]any{"a": "1"})
+	results, err = r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUpdated || len(results[0].Diff.Removed) != 1 {
+		t.Fatalf("b should have been removed: %+v", results[0])
+	}
+
+	secret, err = secrets.Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if _, ok := secret.Data["b"]; ok {
+		t.Fatalf("b should have been removed: %v", secret.Data)
+	}
+	if string(secret.Data["a"]) != "1" || string(secret.Data["other"]) != "x" {
+		t.Fatalf("unexpected data: %v", secret.Data)
+	}
+	if secret.Labels["team"] != "core" || secret.Labels[LabelKey] != DefaultLabelValue {
+		t.Fatalf("unexpected labels: %v", secret.Labels)
+	}
+}
+
+func TestReflectorApplyConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	for _, force := range []bool{false, true} {
+		var opts []Option
+		if force {
+			opts = append(opts, WithForceConflicts())
+		}
+		k8sClient := k8sfake.NewClientset()
+		vaultClient := vault.NewMock(map[string]vault.EngineType{
+			"secrets": vault.EngineTypeKeyValueV1,
+		})
+		r := NewReflector(
+			vaultClient,
+			gsm.NewMockGSM(nil),
+			k8sClient, DefaultNamespace,
+			DefaultLabelValue,
+			opts...,
+		)
+		mapping := Mapping{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "foo",
+			SecretType:      corev1.SecretTypeOpaque,
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		}
+		secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+		vaultClient.Write("secrets/foo", map[string]any{"a": "1"})
+		if err := r.Reflect(ctx, []Mapping{mapping}); err != nil {
+			t.Fatalf("reflect didn't work: %s", err)
+		}
+
+		// another field manager takes over a key pentagon manages
+		secret, err := secrets.Get(ctx, "foo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret should be there: %s", err)
+		}
+		secret.Data["a"] = []byte("edited")
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{FieldManager: "kubectl-edit"})
+		if err != nil {
+			t.Fatalf("unable to update secret: %s", err)
+		}
+
+		vaultClient.Write("secrets/foo", map[string]any{"a": "2"})
+		results, err := r.ReflectResults(ctx, []Mapping{mapping})
+
+		secret, getErr := secrets.Get(ctx, "foo", metav1.GetOptions{})
+		if getErr != nil {
+			t.Fatalf("secret should be there: %s", getErr)
+		}
+
+		if !force {
+			if err == nil || !strings.Contains(err.Error(), "forceConflicts") {
+				t.Fatalf("expected a conflict, got %v", err)
+			}
+			if results[0].Action != ActionFailed {
+				t.Fatalf("expected a failure, got %+v", results[0])
+			}
+			if string(secret.Data["a"]) != "edited" {
+				t.Fatalf("a shouldn't have been changed: %v", secret.Data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("forced reflect didn't work: %s", err)
+		}
+		if results[0].Action != ActionUpdated {
+			t.Fatalf("expected an update, got %+v", results[0])
+		}
+		if string(secret.Data["a"]) != "2" {
+			t.Fatalf("a should have been taken over: %v", secret.Data)
+		}
+	}
+}
+
+func TestReflectorApplyUpgradesManagedFields(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset()
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+	mapping := Mapping{
+		SourceType:      VaultSourceType,
+		Path:            "secrets/foo",
+		SecretName:      "foo",
+		SecretType:      corev1.SecretTypeOpaque,
+		VaultEngineType: vault.EngineTypeKeyValueV1,
+	}
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	// a secret written by pentagon before it used server-side apply
+	_, err := secrets.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "foo",
+			Labels: map[string]string{LabelKey: DefaultLabelValue},
+		},
+		Data: map[string][]byte{"a": []byte("1"), "b": []byte("2")},
+		Type: corev1.SecretTypeOpaque,
+	}, metav1.CreateOptions{FieldManager: FieldManager})
+	if err != nil {
+		t.Fatalf("unable to create secret: %s", err)
+	}
+
+	vaultClient.Write("secrets/foo", map[string]any{"a": "1", "b": "2"})
+	results, err := r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUnchanged {
+		t.Fatalf("upgraded secret should be unchanged: %+v", results[0])
+	}
+
+	secret, err := secrets.Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if len(secret.ManagedFields) != 1 ||
+		secret.ManagedFields[0].Manager != FieldManager ||
+		secret.ManagedFields[0].Operation != metav1.ManagedFieldsOperationApply {
+		t.Fatalf("managed fields should have been upgraded: %+v", secret.ManagedFields)
+	}
+
+	// so keys that were written by updates are removed too
+	vaultClient.Write("secrets/foo", map[string]any{"a": "1"})
+	if err := r.Reflect(ctx, []Mapping{mapping}); err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	secret, err = secrets.Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if _, ok := secret.Data["b"]; ok {
+		t.Fatalf("b should have been removed: %v", secret.Data)
+	}
+}
@@ -0,0 +1,96 @@
+package awssm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// DefaultVersionStage is the staging label that AWS Secrets Manager uses when
+// neither a version ID nor a version stage is requested.
+const DefaultVersionStage = "AWSCURRENT"
+
+// SecretValueGetter exposes the GetSecretValue method from the Secrets Manager Client.
+type SecretValueGetter interface {
+	GetSecretValue(
+		context.Context,
+		*secretsmanager.GetSecretValueInput,
+		...func(*secretsmanager.Options),
+	) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// MockSecretVersion is a single version of a secret stored in a
+// MockSecretsManager.  Exactly one of SecretString and SecretBinary should be
+// set.
+type MockSecretVersion struct {
+	VersionID     string
+	VersionStages []string
+	SecretString  *string
+	SecretBinary  []byte
+}
+
+// MockSecretsManager is a mock AWS Secrets Manager.
+type MockSecretsManager struct {
+	// Secrets maps a secret ID (its name or ARN) to its versions.
+	Secrets map[string][]MockSecretVersion
+}
+
+// NewMockSecretsManager returns a new mock AWS Secrets Manager containing
+// secrets.
+func NewMockSecretsManager(secrets map[string][]MockSecretVersion) *MockSecretsManager {
+	return &MockSecretsManager{
+		Secrets: secrets,
+	}
+}
+
+// GetSecretValue returns the requested version of a secret.  When neither a
+// version ID nor a version stage is requested, the version labeled
+// AWSCURRENT is returned.
+func (m *MockSecretsManager) GetSecretValue(
+	ctx context.Context,
+	input *secretsmanager.GetSecretValueInput,
+	opts ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	secretID := aws.ToString(input.SecretId)
+	versions, ok := m.Secrets[secretID]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{
+			Message: aws.String(fmt.Sprintf("secret %q not found", secretID)),
+		}
+	}
+
+	versionID := aws.ToString(input.VersionId)
+	versionStage := aws.ToString(input.VersionStage)
+	if versionID == "" && versionStage == "" {
+		versionStage = DefaultVersionStage
+	}
+
+	for _, v := range versions {
+		if versionID != "" && v.VersionID != versionID {
+			continue
+		}
+		if versionStage != "" && !slices.Contains(v.VersionStages, versionStage) {
+			continue
+		}
+		return &secretsmanager.GetSecretValueOutput{
+			Name:          aws.String(secretID),
+			VersionId:     aws.String(v.VersionID),
+			VersionStages: v.VersionStages,
+			SecretString:  v.SecretString,
+			SecretBinary:  v.SecretBinary,
+		}, nil
+	}
+
+	return nil, &types.ResourceNotFoundException{
+		Message: aws.String(fmt.Sprintf(
+			"secret %q has no version with ID %q and stage %q",
+			secretID,
+			versionID,
+			versionStage,
+		)),
+	}
+}
@@ -0,0 +1,93 @@
+package awssm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+func TestMockSecretsManager(t *testing.T) {
+	m := NewMockSecretsManager(map[string][]MockSecretVersion{
+		"foo": {
+			{
+				VersionID:     "v1",
+				VersionStages: []string{"AWSPREVIOUS"},
+				SecretString:  aws.String("foo_v1"),
+			},
+			{
+				VersionID:     "v2",
+				VersionStages: []string{"AWSCURRENT"},
+				SecretString:  aws.String("foo_v2"),
+			},
+		},
+		"bar": {
+			{
+				VersionID:     "v1",
+				VersionStages: []string{"AWSCURRENT"},
+				SecretBinary:  []byte{0x00, 0x01},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	// Test the default version stage.
+	resp, err := m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("foo"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(resp.SecretString) != "foo_v2" {
+		t.Fatalf("unexpected current value: %s", aws.ToString(resp.SecretString))
+	}
+
+	// Test explicit version stages.
+	resp, err = m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String("foo"),
+		VersionStage: aws.String("AWSPREVIOUS"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(resp.SecretString) != "foo_v1" {
+		t.Fatalf("unexpected previous value: %s", aws.ToString(resp.SecretString))
+	}
+
+	// Test version IDs.
+	resp, err = m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String("foo"),
+		VersionId: aws.String("v1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(resp.SecretString) != "foo_v1" {
+		t.Fatalf("unexpected v1 value: %s", aws.ToString(resp.SecretString))
+	}
+
+	// Test binary secrets.
+	resp, err = m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("bar"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.SecretBinary) != "\x00\x01" {
+		t.Fatalf("unexpected binary value: %v", resp.SecretBinary)
+	}
+
+	// Test missing secrets and versions.
+	if _, err := m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("baz"),
+	}); err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+	if _, err := m.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String("foo"),
+		VersionId: aws.String("v3"),
+	}); err == nil {
+		t.Fatal("expected error for missing version")
+	}
+}
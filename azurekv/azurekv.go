@@ -0,0 +1,174 @@
+// Package azurekv reads secrets and certificates from Azure Key Vault.
+package azurekv
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// ContentTypePKCS12 is the content type of certificates whose secret is
+	// a base64-encoded PKCS#12 (PFX) archive.
+	ContentTypePKCS12 = "application/x-pkcs12"
+
+	// ContentTypePEM is the content type of certificates whose secret is a
+	// PEM-encoded certificate chain and private key.
+	ContentTypePEM = "application/x-pem-file"
+)
+
+// Secret is a single version of a secret read from Azure Key Vault.
+type Secret struct {
+	// Value is the value of the secret.
+	Value string
+
+	// ContentType is the content type of the secret.  For the secrets backing
+	// certificates it's either ContentTypePKCS12 or ContentTypePEM.
+	ContentType string
+}
+
+// SecretGetter reads secrets from Azure Key Vaults.
+type SecretGetter interface {
+	// GetSecret reads the named secret from the vault at vaultURL.  If version
+	// is empty the latest version is returned.
+	GetSecret(ctx context.Context, vaultURL, name, version string) (*Secret, error)
+}
+
+// Client is a SecretGetter backed by the Azure SDK.  It lazily creates an
+// azsecrets.Client for each vault it's asked to read from.
+type Client struct {
+	credential azcore.TokenCredential
+
+	mu      sync.Mutex
+	clients map[string]*azsecrets.Client
+}
+
+// NewClient returns a Client which authenticates with credential.
+func NewClient(credential azcore.TokenCredential) *Client {
+	return &Client{
+		credential: credential,
+		clients:    map[string]*azsecrets.Client{},
+	}
+}
+
+// GetSecret reads the named secret from the vault at vaultURL.
+func (c *Client) GetSecret(ctx context.Context, vaultURL, name, version string) (*Secret, error) {
+	client, err := c.client(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetSecret(ctx, name, version, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Secret{}
+	if resp.Value != nil {
+		s.Value = *resp.Value
+	}
+	if resp.ContentType != nil {
+		s.ContentType = *resp.ContentType
+	}
+	return s, nil
+}
+
+// client returns the azsecrets.Client for vaultURL, creating it if necessary.
+func (c *Client) client(vaultURL string) (*azsecrets.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[vaultURL]; ok {
+		return client, nil
+	}
+
+	client, err := azsecrets.NewClient(vaultURL, c.credential, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for vault %q: %w", vaultURL, err)
+	}
+	c.clients[vaultURL] = client
+	return client, nil
+}
+
+// CertificateToTLS converts the secret backing an Azure Key Vault certificate
+// into a PEM-encoded certificate chain and private key suitable for the
+// tls.crt and tls.key keys of a kubernetes.io/tls secret.
+func CertificateToTLS(s *Secret) (cert []byte, key []byte, err error) {
+	switch s.ContentType {
+	case ContentTypePKCS12:
+		return pkcs12ToTLS(s.Value)
+	case ContentTypePEM:
+		return pemToTLS(s.Value)
+	default:
+		return nil, nil, fmt.Errorf("unsupported certificate content type %q", s.ContentType)
+	}
+}
+
+func pkcs12ToTLS(value string) ([]byte, []byte, error) {
+	pfx, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding PKCS#12 certificate: %w", err)
+	}
+
+	// certificates stored in Key Vault are exported without a password
+	privateKey, leaf, caCerts, err := pkcs12.DecodeChain(pfx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing PKCS#12 certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshaling private key: %w", err)
+	}
+
+	var cert []byte
+	for _, c := range append([]*x509.Certificate{leaf}, caCerts...) {
+		cert = append(cert, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: c.Raw,
+		})...)
+	}
+	key := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyDER,
+	})
+
+	return cert, key, nil
+}
+
+func pemToTLS(value string) ([]byte, []byte, error) {
+	var cert, key []byte
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert = append(cert, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if key != nil {
+				return nil, nil, fmt.Errorf("PEM certificate contains more than one private key")
+			}
+			key = pem.EncodeToMemory(block)
+		}
+	}
+
+	if cert == nil {
+		return nil, nil, fmt.Errorf("PEM certificate contains no certificates")
+	}
+	if key == nil {
+		return nil, nil, fmt.Errorf("PEM certificate contains no private key")
+	}
+	return cert, key, nil
+}
@@ -0,0 +1,110 @@
+package azurekv
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func testCertificate(t testing.TB) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pentagon.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %s", err)
+	}
+
+	return key, cert
+}
+
+func TestCertificateToTLSPKCS12(t *testing.T) {
+	key, cert := testCertificate(t)
+
+	pfx, err := pkcs12.Modern.Encode(key, cert, nil, "")
+	if err != nil {
+		t.Fatalf("error encoding PKCS#12: %s", err)
+	}
+
+	tlsCert, tlsKey, err := CertificateToTLS(&Secret{
+		Value:       base64.StdEncoding.EncodeToString(pfx),
+		ContentType: ContentTypePKCS12,
+	})
+	if err != nil {
+		t.Fatalf("error converting certificate: %s", err)
+	}
+
+	pair, err := tls.X509KeyPair(tlsCert, tlsKey)
+	if err != nil {
+		t.Fatalf("converted certificate is not a valid key pair: %s", err)
+	}
+	if !bytes.Equal(pair.Certificate[0], cert.Raw) {
+		t.Fatal("converted certificate doesn't match the original")
+	}
+}
+
+func TestCertificateToTLSPEM(t *testing.T) {
+	key, cert := testCertificate(t)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %s", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	// Key Vault puts the private key first
+	tlsCert, tlsKey, err := CertificateToTLS(&Secret{
+		Value:       string(keyPEM) + string(certPEM),
+		ContentType: ContentTypePEM,
+	})
+	if err != nil {
+		t.Fatalf("error converting certificate: %s", err)
+	}
+
+	if !bytes.Equal(tlsCert, certPEM) {
+		t.Errorf("unexpected certificate:\n%s", tlsCert)
+	}
+	if !bytes.Equal(tlsKey, keyPEM) {
+		t.Errorf("unexpected key:\n%s", tlsKey)
+	}
+
+	// a certificate without its key isn't usable
+	if _, _, err := CertificateToTLS(&Secret{
+		Value:       string(certPEM),
+		ContentType: ContentTypePEM,
+	}); err == nil {
+		t.Error("expected an error for a PEM certificate without a key")
+	}
+}
+
+func TestCertificateToTLSUnknownContentType(t *testing.T) {
+	if _, _, err := CertificateToTLS(&Secret{Value: "foo"}); err == nil {
+		t.Fatal("expected an error for a plain secret")
+	}
+}
@@ -0,0 +1,44 @@
+package azurekv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SecretID returns the identifier Azure Key Vault uses for a version of a
+// secret: <vaultURL>/secrets/<name>[/<version>].
+func SecretID(vaultURL, name, version string) string {
+	id := strings.TrimSuffix(vaultURL, "/") + "/secrets/" + name
+	if version != "" {
+		id += "/" + version
+	}
+	return id
+}
+
+// MockAzureKV is a mock Azure Key Vault.
+type MockAzureKV struct {
+	// Data maps secret IDs, as returned by SecretID, to secrets.  The entry
+	// without a version is returned when the latest version is requested.
+	Data map[string]*Secret
+}
+
+// NewMockAzureKV returns a new mock Azure Key Vault containing data.
+func NewMockAzureKV(data map[string]*Secret) *MockAzureKV {
+	return &MockAzureKV{
+		Data: data,
+	}
+}
+
+// GetSecret reads a secret from the mock Azure Key Vault.
+func (m *MockAzureKV) GetSecret(
+	ctx context.Context,
+	vaultURL, name, version string,
+) (*Secret, error) {
+	id := SecretID(vaultURL, name, version)
+	s, ok := m.Data[id]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", id)
+	}
+	return s, nil
+}
@@ -0,0 +1,37 @@
+package azurekv
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMockAzureKV(t *testing.T) {
+	m := NewMockAzureKV(map[string]*Secret{
+		SecretID("https://foo.vault.azure.net/", "bar", ""):   {Value: "bar_latest"},
+		SecretID("https://foo.vault.azure.net/", "bar", "v1"): {Value: "bar_v1"},
+	})
+	ctx := context.Background()
+
+	// Test the latest version.
+	s, err := m.GetSecret(ctx, "https://foo.vault.azure.net", "bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Value != "bar_latest" {
+		t.Fatalf("unexpected latest value: %s", s.Value)
+	}
+
+	// Test a specific version.
+	s, err = m.GetSecret(ctx, "https://foo.vault.azure.net/", "bar", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Value != "bar_v1" {
+		t.Fatalf("unexpected v1 value: %s", s.Value)
+	}
+
+	// Test missing secrets.
+	if _, err := m.GetSecret(ctx, "https://foo.vault.azure.net/", "baz", ""); err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+}
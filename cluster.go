@@ -0,0 +1,77 @@
+package pentagon
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Cluster is a kubernetes cluster that a Reflector writes secrets to.
+type Cluster struct {
+	// Name identifies the cluster in mappings, results and logs.  It may be
+	// empty if it's the only cluster.
+	Name string
+
+	// Client is the client for the cluster.
+	Client kubernetes.Interface
+
+	// Namespace is the namespace that the secrets of mappings without
+	// Namespaces are written to.
+	Namespace string
+}
+
+// cluster is a Cluster along with the state of the current run in it.
+type cluster struct {
+	Cluster
+
+	// secretsSet are the existing kubernetes secrets managed by pentagon,
+	// selectedNamespaces are the namespaces matching each namespace
+	// selector and written are the secrets written by each mapping, all of
+	// which are refreshed on every run.
+	secretsSet         map[types.NamespacedName]corev1.Secret
+	selectedNamespaces map[string][]string
+	written            map[types.NamespacedName]Mapping
+
+	// stopped is set once the cluster can't be written to for the rest of
+	// the run, because it couldn't be reached or a mapping failed without
+	// WithContinueOnError.
+	stopped bool
+}
+
+// reset clears the state of the previous run.
+func (c *cluster) reset() {
+	c.secretsSet = map[types.NamespacedName]corev1.Secret{}
+	c.selectedNamespaces = map[string][]string{}
+	c.written = map[types.NamespacedName]Mapping{}
+	c.stopped = false
+}
+
+// secrets returns the client for the kubernetes secrets in namespace.
+func (c *cluster) secrets(namespace string) typedv1.SecretInterface {
+	return c.Client.CoreV1().Secrets(namespace)
+}
+
+// describe describes the kubernetes secret key in c, for logs and errors.
+func (c *cluster) describe(key types.NamespacedName) string {
+	if c.Name == "" {
+		return key.String()
+	}
+	return fmt.Sprintf("%s in cluster %s", key, c.Name)
+}
+
+// wrap adds the name of c to err, if it has one.
+func (c *cluster) wrap(err error) error {
+	if c.Name == "" {
+		return err
+	}
+	return fmt.Errorf("cluster %s: %w", c.Name, err)
+}
+
+// targets returns true if mapping's secret is written to c.
+func (c *cluster) targets(mapping Mapping) bool {
+	return len(mapping.Clusters) == 0 || slices.Contains(mapping.Clusters, c.Name)
+}
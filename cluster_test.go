@@ -0,0 +1,299 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/metrics"
+	"github.com/vimeo/pentagon/vault"
+)
+
+// describeResults describes the cluster, secret and action of results.
+func describeResults(results []MappingResult) string {
+	var described []string
+	for _, result := range results {
+		described = append(described, fmt.Sprintf(
+			"%s:%s/%s %s",
+			result.Cluster,
+			result.Namespace,
+			result.SecretName,
+			result.Action,
+		))
+	}
+	return strings.Join(described, ", ")
+}
+
+func TestReflectorClusters(t *testing.T) {
+	ctx := context.Background()
+
+	east := k8sfake.NewClientset()
+	west := k8sfake.NewClientset()
+	gsmClient := &countingAccessor{MockGSM: gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/db/versions/latest":  []byte("db"),
+		"projects/foo/secrets/api/versions/latest": []byte("api"),
+	})}
+
+	r := NewClusterReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		[]Cluster{
+			{Name: "east", Client: east, Namespace: DefaultNamespace},
+			{Name: "west", Client: west, Namespace: "apps"},
+		},
+		"test",
+	)
+
+	// db is written to every cluster, and api only to west
+	mappings := []Mapping{
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/db/versions/latest",
+			SecretName:      "db",
+			GSMEncodingType: GSMEncodingTypeDefault,
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/api/versions/latest",
+			SecretName:      "api",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Clusters:        []string{"west"},
+		},
+	}
+
+	// stale secrets are reconciled in each cluster
+	_, err := east.CoreV1().Secrets(DefaultNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "stale",
+			Labels: map[string]string{LabelKey: "test"},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unable to create secret: %s", err)
+	}
+
+	results, err := r.ReflectResults(ctx, mappings)
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	expected := "east:default/db created, west:apps/db created, west:apps/api created, east:default/stale deleted"
+	if described := describeResults(results); described != expected {
+		t.Fatalf("expected %s, got %s", expected, described)
+	}
+	if gsmClient.accessed != 2 {
+		t.Fatalf("each secret should have been fetched once, got %d fetches", gsmClient.accessed)
+	}
+
+	if _, err := east.CoreV1().Secrets(DefaultNamespace).Get(ctx, "db", metav1.GetOptions{}); err != nil {
+		t.Fatalf("db should be in east: %s", err)
+	}
+	if _, err := east.CoreV1().Secrets(DefaultNamespace).Get(ctx, "api", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("api shouldn't be in east: %v", err)
+	}
+	for _, name := range []string{"db", "api"} {
+		if _, err := west.CoreV1().Secrets("apps").Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Fatalf("%s should be in west: %s", name, err)
+		}
+	}
+}
+
+func TestReflectorUnreachableCluster(t *testing.T) {
+	ctx := context.Background()
+
+	east := k8sfake.NewClientset()
+	west := k8sfake.NewClientset()
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/db/versions/latest":  []byte("db"),
+		"projects/foo/secrets/api/versions/latest": []byte("api"),
+	})
+
+	r := NewClusterReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		[]Cluster{
+			{Name: "east", Client: east, Namespace: DefaultNamespace},
+			{Name: "west", Client: west, Namespace: "apps"},
+		},
+		"test",
+	)
+
+	// db is written to every cluster, and api only to west
+	mappings := []Mapping{
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/db/versions/latest",
+			SecretName:      "db",
+			GSMEncodingType: GSMEncodingTypeDefault,
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/api/versions/latest",
+			SecretName:      "api",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Clusters:        []string{"west"},
+		},
+	}
+
+	east.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+
+	results, err := r.ReflectResults(ctx, mappings)
+	if err == nil || !strings.Contains(err.Error(), "cluster east: error listing secrets") {
+		t.Fatalf("expected an error listing secrets in east, got %v", err)
+	}
+
+	expected := "east:/ failed, west:apps/db created, west:apps/api created"
+	if described := describeResults(results); described != expected {
+		t.Fatalf("expected %s, got %s", expected, described)
+	}
+	for _, name := range []string{"db", "api"} {
+		if _, err := west.CoreV1().Secrets("apps").Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Fatalf("%s should be in west: %s", name, err)
+		}
+	}
+}
+
+func TestReflectorClusterFailureStopsCluster(t *testing.T) {
+	ctx := context.Background()
+
+	east := k8sfake.NewClientset()
+	west := k8sfake.NewClientset()
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/db/versions/latest":  []byte("db"),
+		"projects/foo/secrets/api/versions/latest": []byte("api"),
+	})
+
+	r := NewClusterReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		[]Cluster{
+			{Name: "east", Client: east, Namespace: DefaultNamespace},
+			{Name: "west", Client: west, Namespace: "apps"},
+		},
+		"test",
+	)
+
+	west.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.PatchActionImpl).GetName() == "db" {
+			return true, nil, fmt.Errorf("admission webhook denied the request")
+		}
+		return false, nil, nil
+	})
+
+	// without continueOnError, the failure stops west, but not east
+	mappings := []Mapping{
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/db/versions/latest",
+			SecretName:      "db",
+			GSMEncodingType: GSMEncodingTypeDefault,
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/api/versions/latest",
+			SecretName:      "api-west",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Clusters:        []string{"west"},
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/api/versions/latest",
+			SecretName:      "api",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Clusters:        []string{"east"},
+		},
+	}
+
+	results, err := r.ReflectResults(ctx, mappings)
+	if err == nil || !strings.Contains(err.Error(), "cluster west: error creating secret") {
+		t.Fatalf("expected an error creating the secret in west, got %v", err)
+	}
+
+	expected := "east:default/db created, west:apps/db failed, east:default/api created"
+	if described := describeResults(results); described != expected {
+		t.Fatalf("expected %s, got %s", expected, described)
+	}
+	if _, err := east.CoreV1().Secrets(DefaultNamespace).Get(ctx, "api", metav1.GetOptions{}); err != nil {
+		t.Fatalf("api should be in east: %s", err)
+	}
+	if _, err := west.CoreV1().Secrets("apps").Get(ctx, "api-west", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("api-west shouldn't have been written to west once it stopped: %v", err)
+	}
+}
+
+func TestReflectorClusterMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	reg := prometheus.NewRegistry()
+	m, err := metrics.New(reg)
+	if err != nil {
+		t.Fatalf("unable to create metrics: %s", err)
+	}
+
+	east := k8sfake.NewClientset()
+	west := k8sfake.NewClientset()
+	west.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("admission webhook denied the request")
+	})
+
+	r := NewClusterReflector(
+		vault.NewMock(nil),
+		gsm.NewMockGSM(map[string][]byte{
+			"projects/foo/secrets/db/versions/latest": []byte("db"),
+		}),
+		[]Cluster{
+			{Name: "east", Client: east, Namespace: DefaultNamespace},
+			{Name: "west", Client: west, Namespace: DefaultNamespace},
+		},
+		DefaultLabelValue,
+		WithMetrics(m),
+		WithContinueOnError(),
+	)
+
+	_, err = r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/db/versions/latest",
+			SecretName:      "db",
+			GSMEncodingType: GSMEncodingTypeDefault,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cluster west") {
+		t.Fatalf("expected an error in west, got %v", err)
+	}
+
+	// west's failure is recorded against west, and doesn't get a sync from
+	// east's success
+	expected := `
+# HELP pentagon_errors_total Number of errors encountered while reflecting secrets, by phase.
+# TYPE pentagon_errors_total counter
+pentagon_errors_total{cluster="west",namespace="default",phase="create",secret_name="db"} 1
+# HELP pentagon_syncs_total Number of successful syncs of a source secret into a kubernetes secret.
+# TYPE pentagon_syncs_total counter
+pentagon_syncs_total{cluster="east",namespace="default",secret_name="db",source_type="gsm"} 1
+`
+	if err := testutil.GatherAndCompare(
+		reg,
+		strings.NewReader(expected),
+		"pentagon_errors_total",
+		"pentagon_syncs_total",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if c := testutil.CollectAndCount(reg, "pentagon_last_successful_sync_timestamp_seconds"); c != 1 {
+		t.Fatalf("only east should have a last successful sync, got %d series", c)
+	}
+}
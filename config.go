@@ -3,13 +3,18 @@ package pentagon
 import (
 	"fmt"
 	"log"
+	"maps"
 	"path"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/vimeo/pentagon/vault"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -26,14 +31,80 @@ const (
 	// GSMSourceType indicates a mapping sourced from Google Secrets Manager.
 	GSMSourceType = "gsm"
 
+	// AWSSourceType indicates a mapping sourced from AWS Secrets Manager.
+	AWSSourceType = "aws-sm"
+
+	// AzureSourceType indicates a mapping sourced from Azure Key Vault.
+	AzureSourceType = "azure-kv"
+
 	// GSM encoded as just raw bytes (default)
 	GSMEncodingTypeDefault = "default"
 
 	// GSM encoded as json
 	GSMEncodingTypeJSON = "json"
 
+	// AWS Secrets Manager secret used as just raw bytes (default)
+	AWSEncodingTypeDefault = "default"
+
+	// AWS Secrets Manager secret encoded as json
+	AWSEncodingTypeJSON = "json"
+
+	// AzureObjectTypeSecret reads an Azure Key Vault secret (default).
+	AzureObjectTypeSecret = "secret"
+
+	// AzureObjectTypeCertificate reads an Azure Key Vault certificate and its
+	// private key into the tls.crt and tls.key keys.
+	AzureObjectTypeCertificate = "certificate"
+
+	// VaultListOneLevel reflects the Vault secrets directly under a mapping's
+	// path.
+	VaultListOneLevel = "one-level"
+
+	// VaultListRecursive reflects all the Vault secrets under a mapping's
+	// path.
+	VaultListRecursive = "recursive"
+
+	// DefaultSecretNameTemplate names the kubernetes secrets of a VaultList
+	// mapping after the paths of the Vault secrets relative to its path, and
+	// those of a GSMFilter mapping after the IDs of the GSM secrets.
+	DefaultSecretNameTemplate = "{{ .Path }}"
+
+	// NestedValuesJSON writes nested objects and arrays in Vault secrets as
+	// JSON (default).
+	NestedValuesJSON = "json"
+
+	// NestedValuesFlatten flattens nested objects and arrays in Vault
+	// secrets into keys joined by dots, e.g. {"db": {"port": 5432}} becomes
+	// the key db.port.
+	NestedValuesFlatten = "flatten"
+
+	// KeyCollisionError fails a mapping with sources if more than one of
+	// them has the same key (default).
+	KeyCollisionError = "error"
+
+	// KeyCollisionFirstWins keeps the value of the first source with a key
+	// when more than one of a mapping's sources has it.
+	KeyCollisionFirstWins = "first-wins"
+
+	// KeyCollisionLastWins keeps the value of the last source with a key
+	// when more than one of a mapping's sources has it.
+	KeyCollisionLastWins = "last-wins"
+
 	// when a version isn't specified, just default to the latest
 	gsmLatestSuffix = "/versions/latest"
+
+	// DefaultRefreshInterval is how often mappings are re-reflected in daemon
+	// mode when neither the config nor the mapping specify an interval.
+	DefaultRefreshInterval = time.Hour
+
+	// DefaultRetryInterval is how soon a mapping that failed is retried in
+	// daemon mode.  It doubles with every consecutive failure, up to the
+	// mapping's refresh interval.
+	DefaultRetryInterval = 30 * time.Second
+
+	// DefaultClusterTimeout is how long requests to a kubernetes cluster in
+	// Clusters can take before it's considered unreachable.
+	DefaultClusterTimeout = 30 * time.Second
 )
 
 // regex to match the version suffix at the end of a GSM secret path.  Note that
@@ -52,10 +123,63 @@ type Config struct {
 	// k8s secrets created by pentagon.
 	Label string `yaml:"label"`
 
+	// RefreshInterval is how often each mapping is re-reflected when running
+	// in daemon mode.  Mappings may override this with their own
+	// RefreshInterval.  Defaults to DefaultRefreshInterval.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+
+	// ContinueOnError keeps reflecting the remaining mappings when one of
+	// them fails rather than stopping at the first failure.  Reconciliation
+	// still runs, but never deletes the secrets of failed mappings.
+	ContinueOnError bool `yaml:"continueOnError"`
+
+	// ConflictPolicy decides what happens when the kubernetes secret of a
+	// mapping already exists but isn't managed by pentagon: "fail" (the
+	// default), "skip" or "adopt".  Mappings can override it.
+	ConflictPolicy string `yaml:"conflictPolicy"`
+
+	// ForceConflicts makes pentagon take ownership of the fields of its
+	// secrets that another field manager has since changed, instead of
+	// failing the mapping with a conflict.
+	ForceConflicts bool `yaml:"forceConflicts"`
+
+	// Clusters are the kubernetes clusters that secrets are written to.  If
+	// there are none, secrets are written to the cluster that pentagon runs
+	// in.
+	Clusters []ClusterConfig `yaml:"clusters"`
+
 	// Mappings is a list of mappings.
 	Mappings []Mapping `yaml:"mappings"`
 }
 
+// ClusterConfig describes how to connect to a kubernetes cluster that
+// secrets are written to, either from inside it or with a kubeconfig.
+type ClusterConfig struct {
+	// Name identifies the cluster in mappings and results.
+	Name string `yaml:"name"`
+
+	// InCluster connects to the cluster that pentagon runs in.
+	InCluster bool `yaml:"inCluster"`
+
+	// Kubeconfig is the path of the kubeconfig file to connect with.  If
+	// only Context is set, the default kubeconfig is used ($KUBECONFIG or
+	// ~/.kube/config).
+	Kubeconfig string `yaml:"kubeconfig"`
+
+	// Context is the kubeconfig context to use.  Defaults to the current
+	// context of the kubeconfig.
+	Context string `yaml:"context"`
+
+	// Namespace is the namespace that the secrets of mappings without
+	// Namespaces are written to in this cluster.  Defaults to the Namespace
+	// in Config.
+	Namespace string `yaml:"namespace"`
+
+	// Timeout is how long requests to the cluster can take.  Defaults to
+	// DefaultClusterTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
 // SetDefaults sets defaults for the Namespace and Label in case they're
 // not passed in from the configuration file.
 func (c *Config) SetDefaults() {
@@ -67,41 +191,110 @@ func (c *Config) SetDefaults() {
 		c.Label = DefaultLabelValue
 	}
 
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = DefaultRefreshInterval
+	}
+
 	// default to engine type key/value v1 for backward compatibility
 	if c.Vault.DefaultEngineType == "" {
 		c.Vault.DefaultEngineType = vault.EngineTypeKeyValueV1
 	}
 
+	if c.ConflictPolicy == "" {
+		c.ConflictPolicy = ConflictPolicyFail
+	}
+
+	if c.Vault.AuthNamespace == "" {
+		c.Vault.AuthNamespace = c.Vault.Namespace
+	}
+
+	for i := range c.Clusters {
+		if c.Clusters[i].Namespace == "" {
+			c.Clusters[i].Namespace = c.Namespace
+		}
+		if c.Clusters[i].Timeout == 0 {
+			c.Clusters[i].Timeout = DefaultClusterTimeout
+		}
+	}
+
 	// set all the underlying mapping engine types to their default
 	// if unspecified
-	for i, m := range c.Mappings {
-		// default to vault source type for backward compatibility
-		if m.SourceType == "" {
-			c.Mappings[i].SourceType = VaultSourceType
-		}
+	for i := range c.Mappings {
+		c.setMappingDefaults(&c.Mappings[i])
+	}
+}
 
-		// copy VaultPath to Path for backward compatibility
-		if m.Path == "" && m.VaultPath != "" {
-			log.Println("WARNING: Use mapping.Path instead of mapping.VaultPath (deprecated)")
-			c.Mappings[i].Path = m.VaultPath
-		}
+// setMappingDefaults sets the defaults of a single mapping and its sources.
+func (c *Config) setMappingDefaults(m *Mapping) {
+	// default to vault source type for backward compatibility.  Mappings
+	// with sources don't have a source type of their own.
+	if m.SourceType == "" && len(m.Sources) == 0 {
+		m.SourceType = VaultSourceType
+	}
 
-		if m.GSMEncodingType == "" {
-			c.Mappings[i].GSMEncodingType = GSMEncodingTypeDefault
-		}
+	// copy VaultPath to Path for backward compatibility
+	if m.Path == "" && m.VaultPath != "" {
+		log.Println("WARNING: Use mapping.Path instead of mapping.VaultPath (deprecated)")
+		m.Path = m.VaultPath
+	}
 
-		if m.VaultEngineType == "" {
-			c.Mappings[i].VaultEngineType = c.Vault.DefaultEngineType
-		}
+	if m.GSMEncodingType == "" {
+		m.GSMEncodingType = GSMEncodingTypeDefault
+	}
 
-		if m.SecretType == "" {
-			c.Mappings[i].SecretType = corev1.SecretTypeOpaque
-		}
+	if m.AWSEncodingType == "" {
+		m.AWSEncodingType = AWSEncodingTypeDefault
+	}
+
+	if m.VaultEngineType == "" {
+		m.VaultEngineType = c.Vault.DefaultEngineType
+	}
+
+	if m.VaultNamespace == "" {
+		m.VaultNamespace = c.Vault.Namespace
+	}
 
-		if m.SourceType == GSMSourceType && !gsmVersionSuffix.MatchString(m.Path) {
-			c.Mappings[i].Path = path.Join(m.Path, gsmLatestSuffix)
+	if isListMapping(*m) && m.SecretNameTemplate == "" {
+		m.SecretNameTemplate = DefaultSecretNameTemplate
+	}
+
+	if m.SourceType == AzureSourceType && m.AzureObjectType == "" {
+		m.AzureObjectType = AzureObjectTypeSecret
+	}
+
+	if m.SecretType == "" {
+		m.SecretType = corev1.SecretTypeOpaque
+		if m.AzureObjectType == AzureObjectTypeCertificate {
+			m.SecretType = corev1.SecretTypeTLS
 		}
 	}
+
+	if m.RefreshInterval == 0 {
+		m.RefreshInterval = c.RefreshInterval
+	}
+
+	if m.ConflictPolicy == "" {
+		m.ConflictPolicy = c.ConflictPolicy
+	}
+
+	if m.SourceType == GSMSourceType && m.GSMFilter == "" && !gsmVersionSuffix.MatchString(m.Path) {
+		m.Path = path.Join(m.Path, gsmLatestSuffix)
+	}
+
+	if m.SourceType == VaultSourceType && m.VaultNestedValues == "" {
+		m.VaultNestedValues = NestedValuesJSON
+	}
+
+	if m.OutputFormat != "" && m.OutputKey == "" {
+		m.OutputKey = defaultOutputKeys[m.OutputFormat]
+	}
+
+	if len(m.Sources) > 0 && m.KeyCollisionPolicy == "" {
+		m.KeyCollisionPolicy = KeyCollisionError
+	}
+	for i := range m.Sources {
+		c.setMappingDefaults(&m.Sources[i])
+	}
 }
 
 // Validate checks to make sure that the configuration is valid.
@@ -114,17 +307,287 @@ func (c *Config) Validate() error {
 		"":              {},
 		VaultSourceType: {},
 		GSMSourceType:   {},
+		AWSSourceType:   {},
+		AzureSourceType: {},
 	}
 
-	for _, m := range c.Mappings {
+	validVaultLists := map[string]struct{}{
+		"":                 {},
+		VaultListOneLevel:  {},
+		VaultListRecursive: {},
+	}
+
+	validAzureObjectTypes := map[string]struct{}{
+		"":                         {},
+		AzureObjectTypeSecret:      {},
+		AzureObjectTypeCertificate: {},
+	}
+
+	if c.RefreshInterval < 0 {
+		return fmt.Errorf("refresh interval should not be negative: %s", c.RefreshInterval)
+	}
+	if _, ok := validConflictPolicies[c.ConflictPolicy]; !ok {
+		return fmt.Errorf("invalid conflict policy: %+v", c.ConflictPolicy)
+	}
+
+	// clusterNamespaces are the default namespaces of each cluster, by
+	// name.  Without any clusters, there's just the unnamed one pentagon
+	// runs in.
+	clusterNamespaces := map[string]string{"": c.Namespace}
+	if len(c.Clusters) > 0 {
+		clusterNamespaces = make(map[string]string, len(c.Clusters))
+	}
+	for _, cluster := range c.Clusters {
+		if err := cluster.validate(); err != nil {
+			return err
+		}
+		if _, ok := clusterNamespaces[cluster.Name]; ok {
+			return fmt.Errorf("duplicate cluster name: %s", cluster.Name)
+		}
+		clusterNamespaces[cluster.Name] = cluster.Namespace
+		if cluster.Namespace == "" {
+			clusterNamespaces[cluster.Name] = c.Namespace
+		}
+	}
+
+	validate := func(m Mapping) error {
 		if _, ok := validSourceTypes[m.SourceType]; !ok {
 			return fmt.Errorf("invalid source type: %+v", m.SourceType)
 		}
 		if m.Path == "" {
 			return fmt.Errorf("path should not be empty: %+v", m)
 		}
+		if m.RefreshInterval < 0 {
+			return fmt.Errorf("refresh interval should not be negative: %+v", m)
+		}
+		if m.SourceType == AzureSourceType && m.AzureVaultURL == "" {
+			return fmt.Errorf("azure vault url should not be empty: %+v", m)
+		}
+		if m.VaultVersion < 0 {
+			return fmt.Errorf("vault version should not be negative: %+v", m)
+		}
+		if m.VaultVersion != 0 && m.VaultEngineType == vault.EngineTypeKeyValueV1 {
+			return fmt.Errorf("vault version requires a key/value v2 engine: %+v", m)
+		}
+		switch m.VaultNestedValues {
+		case "", NestedValuesJSON, NestedValuesFlatten:
+		default:
+			return fmt.Errorf("invalid vault nested values: %+v", m.VaultNestedValues)
+		}
+		if err := validateVaultList(m, validVaultLists); err != nil {
+			return err
+		}
+		if err := validateGSMFilter(m); err != nil {
+			return err
+		}
+		if _, ok := validAzureObjectTypes[m.AzureObjectType]; !ok {
+			return fmt.Errorf("invalid azure object type: %+v", m.AzureObjectType)
+		}
+		if err := validateDecode(m); err != nil {
+			return err
+		}
+		if err := m.Keys.validate(); err != nil {
+			return fmt.Errorf("invalid key rules for %s: %s", m.Path, err)
+		}
+		if err := validateTemplates(m.Templates); err != nil {
+			return fmt.Errorf("invalid templates for %s: %s", m.Path, err)
+		}
+		if err := validateOutputFormat(m); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// secretNames are the mappings writing to each kubernetes secret, by
+	// namespace/name, so that mappings can't silently overwrite each other
+	secretNames := map[string]Mapping{}
+	for _, m := range c.Mappings {
+		if _, ok := validConflictPolicies[m.ConflictPolicy]; !ok {
+			return fmt.Errorf("invalid conflict policy: %+v", m.ConflictPolicy)
+		}
+		if len(m.Sources) > 0 {
+			if err := validateSources(m, validate); err != nil {
+				return err
+			}
+		} else if err := validate(m); err != nil {
+			return err
+		}
+
+		if err := m.Namespaces.validate(); err != nil {
+			return err
+		}
+		clusters := m.Clusters
+		if len(clusters) == 0 {
+			clusters = slices.Sorted(maps.Keys(clusterNamespaces))
+		}
+		for _, cluster := range m.Clusters {
+			if _, ok := clusterNamespaces[cluster]; !ok || len(c.Clusters) == 0 {
+				return fmt.Errorf("unknown cluster %q in mapping %+v", cluster, m)
+			}
+		}
+
+		// namespaces matching selectors aren't known until pentagon runs,
+		// so overlaps between them are caught then
+		if m.SecretName == "" {
+			continue
+		}
+		for _, cluster := range clusters {
+			namespaces := m.Namespaces.Names
+			if m.Namespaces.empty() {
+				namespaces = []string{clusterNamespaces[cluster]}
+			}
+			for _, namespace := range namespaces {
+				name := namespace + "/" + m.SecretName
+				if cluster != "" {
+					name += " in cluster " + cluster
+				}
+				if other, ok := secretNames[name]; ok {
+					return fmt.Errorf(
+						"mappings %+v and %+v both write to kubernetes secret %s (use sources to merge them)",
+						other,
+						m,
+						name,
+					)
+				}
+				secretNames[name] = m
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSources validates a mapping with sources, and each of its sources
+// with validate.
+func validateSources(m Mapping, validate func(Mapping) error) error {
+	if m.SourceType != "" || m.Path != "" || m.VaultPath != "" {
+		return fmt.Errorf("mappings with sources should not have a source type or path of their own: %+v", m)
+	}
+	if isListMapping(m) {
+		return fmt.Errorf("mappings with sources can't list secrets: %+v", m)
+	}
+	if m.SecretName == "" {
+		return fmt.Errorf("secret name should not be empty for mappings with sources: %+v", m)
+	}
+	if m.RefreshInterval < 0 {
+		return fmt.Errorf("refresh interval should not be negative: %+v", m)
+	}
+	if err := validateDecode(m); err != nil {
+		return err
+	}
+	if err := m.Keys.validate(); err != nil {
+		return fmt.Errorf("invalid key rules for %s: %s", m.SecretName, err)
+	}
+	if err := validateTemplates(m.Templates); err != nil {
+		return fmt.Errorf("invalid templates for %s: %s", m.SecretName, err)
+	}
+	if err := validateOutputFormat(m); err != nil {
+		return err
+	}
+	switch m.KeyCollisionPolicy {
+	case "", KeyCollisionError, KeyCollisionFirstWins, KeyCollisionLastWins:
+	default:
+		return fmt.Errorf("invalid key collision policy: %+v", m.KeyCollisionPolicy)
+	}
+
+	for _, source := range m.Sources {
+		if len(source.Sources) > 0 {
+			return fmt.Errorf("sources can't have sources of their own: %+v", source)
+		}
+		if source.SecretName != "" || !source.Namespaces.empty() || len(source.Clusters) > 0 || isListMapping(source) {
+			return fmt.Errorf("sources are written to the secret of their mapping: %+v", source)
+		}
+		if err := validate(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateVaultList validates the VaultList settings of m.
+func validateVaultList(m Mapping, validVaultLists map[string]struct{}) error {
+	if _, ok := validVaultLists[m.VaultList]; !ok {
+		return fmt.Errorf("invalid vault list: %+v", m.VaultList)
+	}
+	if m.VaultList == "" {
+		return nil
+	}
+
+	if m.SourceType != "" && m.SourceType != VaultSourceType {
+		return fmt.Errorf("vault list requires the vault source type: %+v", m)
+	}
+	if m.SecretName != "" {
+		return fmt.Errorf("vault list mappings are named with secretNameTemplate rather than secretName: %+v", m)
+	}
+	if m.VaultVersion != 0 {
+		return fmt.Errorf("vault list mappings can't pin a vault version: %+v", m)
+	}
+	if _, err := parseSecretNameTemplate(m.SecretNameTemplate); err != nil {
+		return fmt.Errorf("invalid secret name template: %s", err)
+	}
+	return nil
+}
+
+// validateGSMFilter validates the GSMFilter settings of m.
+func validateGSMFilter(m Mapping) error {
+	if m.GSMFilter == "" {
+		return nil
 	}
 
+	if m.SourceType != GSMSourceType {
+		return fmt.Errorf("gsm filter requires the gsm source type: %+v", m)
+	}
+	if m.SecretName != "" {
+		return fmt.Errorf("gsm filter mappings are named with secretNameTemplate rather than secretName: %+v", m)
+	}
+	if strings.Contains(m.Path, "/secrets/") {
+		return fmt.Errorf("the path of a gsm filter mapping should be a project, not a secret: %+v", m)
+	}
+	if _, err := parseSecretNameTemplate(m.SecretNameTemplate); err != nil {
+		return fmt.Errorf("invalid secret name template: %s", err)
+	}
+	return nil
+}
+
+// validateOutputFormat validates the OutputFormat settings of m.
+func validateOutputFormat(m Mapping) error {
+	if m.OutputFormat == "" {
+		if m.OutputKey != "" {
+			return fmt.Errorf("output key requires an output format: %+v", m)
+		}
+		return nil
+	}
+
+	if _, ok := defaultOutputKeys[m.OutputFormat]; !ok {
+		return fmt.Errorf("invalid output format: %+v", m.OutputFormat)
+	}
+	if m.OutputKey != "" {
+		if errs := validation.IsConfigMapKey(m.OutputKey); len(errs) > 0 {
+			return fmt.Errorf("invalid output key %q: %s", m.OutputKey, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// validate checks that c names the cluster and says how to connect to it.
+func (c ClusterConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("cluster name should not be empty: %+v", c)
+	}
+	if !c.InCluster && c.Kubeconfig == "" && c.Context == "" {
+		return fmt.Errorf("cluster %s needs inCluster, a kubeconfig or a context", c.Name)
+	}
+	if c.InCluster && (c.Kubeconfig != "" || c.Context != "") {
+		return fmt.Errorf("cluster %s can't be inCluster and use a kubeconfig", c.Name)
+	}
+	if c.Namespace != "" {
+		if errs := validation.IsDNS1123Label(c.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q for cluster %s: %s", c.Namespace, c.Name, strings.Join(errs, ", "))
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout should not be negative for cluster %s: %s", c.Name, c.Timeout)
+	}
 	return nil
 }
 
@@ -133,38 +596,80 @@ type VaultConfig struct {
 	// URL is the url to the vault server.
 	URL string `yaml:"url"`
 
-	// AuthType can be "token" or "gcp-default".
+	// AuthType can be "token", "gcp-default", "kubernetes" or "approle".
 	AuthType vault.AuthType `yaml:"authType"`
 
+	// AuthMountPath is the path at which the auth method is mounted, for
+	// auth methods other than "token".  Defaults to the name of the auth
+	// method (e.g. "kubernetes" or "gcp").
+	AuthMountPath string `yaml:"authMountPath"`
+
+	// Namespace is the Vault Enterprise namespace that secrets are read from,
+	// unless a mapping overrides it.  The root namespace is used if it's
+	// empty.
+	Namespace string `yaml:"namespace"`
+
+	// AuthNamespace is the Vault Enterprise namespace in which pentagon logs
+	// in, which may be a parent of the namespaces that secrets are read from.
+	// Defaults to Namespace.
+	AuthNamespace string `yaml:"authNamespace"`
+
 	// DefaultEngineType is the type of secrets engine used because the API
 	// responses may differ based on the engine used.  In particular, K/V v2
 	// has an extra layer of data wrapping that differs from v1.
-	// Allowed values are "kv" and "kv-v2".
+	// Allowed values are "kv", "kv-v2" and "auto", which detects the engine
+	// type from the mount each path belongs to.
 	DefaultEngineType vault.EngineType `yaml:"defaultEngineType"`
 
 	// Role is the role used when authenticating with vault.  If this is unset
-	// the role will be discovered by querying the GCP metadata service for
-	// the default service account's email address and using the "user" portion
-	// (before the '@').
+	// and AuthType == "gcp-default", the role will be discovered by querying
+	// the GCP metadata service for the default service account's email
+	// address and using the "user" portion (before the '@').  It's required
+	// when AuthType == "kubernetes".
 	Role string `yaml:"role"` // used for non-token auth
 
+	// KubernetesTokenPath is the path to the service account token used when
+	// AuthType == "kubernetes".  Defaults to
+	// /var/run/secrets/kubernetes.io/serviceaccount/token.
+	KubernetesTokenPath string `yaml:"kubernetesTokenPath"`
+
 	// Token is a vault token and is only considered when AuthType == "token".
 	Token string `yaml:"token"`
 
+	// AppRole configures the credentials used when AuthType == "approle".
+	AppRole AppRoleConfig `yaml:"appRole"`
+
 	// TLSConfig allows you to set any TLS options that the vault client
 	// accepts.
 	TLSConfig *api.TLSConfig `yaml:"tls"` // for other vault TLS options
 }
 
+// AppRoleConfig is the configuration for vault's approle auth method.  The
+// credentials are read from files or environment variables rather than being
+// inlined in the configuration.
+type AppRoleConfig struct {
+	// RoleID is where to read the approle role_id from.
+	RoleID vault.CredentialSource `yaml:"roleId"`
+
+	// SecretID is where to read the approle secret_id from.
+	SecretID vault.CredentialSource `yaml:"secretId"`
+}
+
 // Mapping is a single mapping for a vault secret to a k8s secret.
 type Mapping struct {
-	// SourceType is the source of a secret: Vault or GSM. Defaults to Vault.
+	// SourceType is the source of a secret: Vault, GSM, AWS Secrets Manager
+	// or Azure Key Vault. Defaults to Vault.
 	SourceType string `yaml:"sourceType"`
 
-	// Path is the path to a Vault or GSM secret.
+	// Path is the path to a Vault, GSM, AWS Secrets Manager or Azure Key
+	// Vault secret.
 	// GSM secrets can use one of the following forms;
 	// - projects/*/secrets/*/versions/*
 	// - projects/*/locations/*/secrets/*/versions/*
+	// For GSMFilter mappings it's the project (projects/*) or location
+	// (projects/*/locations/*) whose secrets are listed.
+	// AWS Secrets Manager secrets can be referenced by name or ARN.
+	// Azure Key Vault secrets and certificates are referenced by name.
 	Path string `yaml:"path"`
 
 	// [DEPRECATED] VaultPath is the path to a vault secret. Use Path instead.
@@ -175,14 +680,56 @@ type Mapping struct {
 	// match the regex [a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*
 	SecretName string `yaml:"secretName"`
 
+	// Namespaces are the k8s namespaces that the secret is written to, by
+	// name or label selector.  Defaults to the Namespace in Config.
+	Namespaces Namespaces `yaml:"namespaces"`
+
+	// Clusters are the names of the clusters in Config that the secret is
+	// written to.  Defaults to all of them.
+	Clusters []string `yaml:"clusters"`
+
 	// SecretType is a k8s SecretType type (string)
 	SecretType corev1.SecretType `yaml:"secretType"`
 
+	// VaultNamespace is the Vault Enterprise namespace containing the path of
+	// this Vault secret.  Defaults to the Namespace in VaultConfig.
+	VaultNamespace string `yaml:"vaultNamespace"`
+
+	// VaultVersion pins the version of a key/value v2 Vault secret that's
+	// read.  The latest version is read if it's 0.
+	VaultVersion int `yaml:"vaultVersion"`
+
 	// VaultEngineType is the type of secrets engine mounted at the path of this
 	// Vault secret.  This specifically overrides the DefaultEngineType
 	// specified in VaultConfig.
 	VaultEngineType vault.EngineType `yaml:"vaultEngineType"`
 
+	// VaultNestedValues decides how nested objects and arrays in a Vault
+	// secret are written: "json" (the default) or "flatten".
+	VaultNestedValues string `yaml:"vaultNestedValues"`
+
+	// VaultList turns this mapping into one kubernetes secret per Vault
+	// secret under Path, which is listed either "one-level" deep or
+	// "recursive"ly.  The kubernetes secrets are named with
+	// SecretNameTemplate rather than SecretName.
+	VaultList string `yaml:"vaultList"`
+
+	// SecretNameTemplate is a text/template that names the kubernetes
+	// secrets of a VaultList or GSMFilter mapping.  For VaultList it's
+	// executed with .Path, the path of each Vault secret relative to Path,
+	// and .Name, the last element of that path.  For GSMFilter both .Path and
+	// .Name are the ID of each GSM secret, and .Labels are its labels.  The
+	// result is sanitized to be a DNS-1123-compatible name.  Defaults to
+	// DefaultSecretNameTemplate.
+	SecretNameTemplate string `yaml:"secretNameTemplate"`
+
+	// GSMFilter turns this mapping into one kubernetes secret per GSM secret
+	// in the project at Path that matches the filter, e.g.
+	// "labels.k8s-sync=true".  The latest version of each secret is
+	// reflected, and the kubernetes secrets are named with
+	// SecretNameTemplate rather than SecretName.
+	GSMFilter string `yaml:"gsmFilter"`
+
 	// GSMEncodingType enables the parsing of JSON secrets with more than one key-value pair when set
 	// to 'json'. For the default behavior, simple values, set to 'string'.
 	GSMEncodingType string `yaml:"gsmEncodingType"`
@@ -192,7 +739,98 @@ type Mapping struct {
 	// this is unset, the key name will default to the value of secretName.
 	GSMSecretKeyValue string `yaml:"gsmSecretKeyValue"`
 
+	// AWSVersionStage selects the version of an AWS Secrets Manager secret by
+	// its staging label (e.g. "AWSPREVIOUS").  If neither this nor
+	// AWSVersionID are set, the "AWSCURRENT" version is used.
+	AWSVersionStage string `yaml:"awsVersionStage"`
+
+	// AWSVersionID selects the version of an AWS Secrets Manager secret by its
+	// unique version ID.
+	AWSVersionID string `yaml:"awsVersionId"`
+
+	// AWSEncodingType enables the parsing of JSON secrets with more than one
+	// key-value pair when set to 'json', just like GSMEncodingType.
+	AWSEncodingType string `yaml:"awsEncodingType"`
+
+	// AWSSecretKeyValue allows you to specify the value of the Kubernetes key
+	// to use for this secret's value in cases where awsEncodingType is *not*
+	// json.  If this is unset, the key name will default to the value of
+	// secretName.
+	AWSSecretKeyValue string `yaml:"awsSecretKeyValue"`
+
+	// AzureVaultURL is the URL of the Azure Key Vault containing the secret,
+	// e.g. https://my-vault.vault.azure.net/.
+	AzureVaultURL string `yaml:"azureVaultUrl"`
+
+	// AzureVersion is the version of the Azure Key Vault secret or
+	// certificate to read.  If this is unset, the latest version is used.
+	AzureVersion string `yaml:"azureVersion"`
+
+	// AzureObjectType is either "secret" (the default) or "certificate".
+	// Certificates are written as a kubernetes.io/tls secret with tls.crt and
+	// tls.key keys.
+	AzureObjectType string `yaml:"azureObjectType"`
+
+	// AzureSecretKeyValue allows you to specify the value of the Kubernetes
+	// key to use for an Azure Key Vault secret's value.  If this is unset, the
+	// key name will default to the value of secretName.
+	AzureSecretKeyValue string `yaml:"azureSecretKeyValue"`
+
+	// Decode decodes every value of this secret before it's written:
+	// "base64", "base64url" or "hex".  This is for binary data, like
+	// keystores, that's stored encoded in its source.
+	Decode string `yaml:"decode"`
+
+	// DecodeKeys decodes the values of individual source keys, overriding
+	// Decode.
+	DecodeKeys map[string]string `yaml:"decodeKeys"`
+
+	// Keys selects and renames the keys that are written to the kubernetes
+	// secret.  For mappings with sources, each source can have its own key
+	// rules, which are applied before the sources are merged.
+	Keys KeyRules `yaml:"keys"`
+
+	// Templates, if set, replace the keys of the kubernetes secret with the
+	// keys given here, whose values are text/templates rendered over the
+	// fetched data (after Keys are applied).  The data is a map of strings,
+	// so keys are referred to like {{ .db_user }}, and referring to a key
+	// that doesn't exist is an error.
+	Templates map[string]string `yaml:"templates"`
+
+	// OutputFormat, if set, serializes all the keys of the kubernetes
+	// secret (after Keys and Templates are applied) into the single key
+	// OutputKey: "dotenv", "json", "yaml" or "properties".
+	OutputFormat string `yaml:"outputFormat"`
+
+	// OutputKey is the key that OutputFormat is written to.  Defaults to
+	// ".env", "secrets.json", "secrets.yaml" or "application.properties",
+	// depending on the format.
+	OutputKey string `yaml:"outputKey"`
+
+	// Sources are the secrets that are merged into this mapping's kubernetes
+	// secret, instead of the single secret at Path.  Each source is
+	// configured like a mapping with a SourceType, Path and any source
+	// specific settings, but without a SecretName.
+	Sources []Mapping `yaml:"sources"`
+
+	// KeyCollisionPolicy decides what happens when more than one of Sources
+	// has the same key: "error" (the default), "first-wins" or "last-wins".
+	KeyCollisionPolicy string `yaml:"keyCollisionPolicy"`
+
+	// ConflictPolicy overrides the ConflictPolicy in Config for this
+	// mapping.
+	ConflictPolicy string `yaml:"conflictPolicy"`
+
 	// AdditionalSecretLabels allows you to specify the additional labels that will be
 	// added to the created Kubernetes secret.
 	AdditionalSecretLabels map[string]string `yaml:"additionalSecretLabels"`
+
+	// RefreshInterval is how often this mapping is re-reflected when running
+	// in daemon mode.  This overrides the RefreshInterval specified in
+	// Config.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+
+	// listPath is the Path of the VaultList or GSMFilter mapping that this
+	// mapping was generated from, if any.
+	listPath string
 }
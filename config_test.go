@@ -1,7 +1,13 @@
 package pentagon
 
 import (
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/vimeo/pentagon/vault"
 )
@@ -83,6 +89,9 @@ func TestSetDefaults(t *testing.T) {
 		if m.SecretType == "" {
 			t.Fatalf("empty Kubernetes secret type for mapping: %+v", m)
 		}
+		if m.AWSEncodingType == "" {
+			t.Fatalf("empty AWS encoding type for mapping: %+v", m)
+		}
 	}
 
 	if c.Mappings[2].Path != "projects/my-project/secrets/my-secret/versions/latest" {
@@ -162,6 +171,8 @@ func TestValidSourceTypes(t *testing.T) {
 			{SourceType: "", Path: "foo"},
 			{SourceType: VaultSourceType, Path: "foo"},
 			{SourceType: GSMSourceType, Path: "foo"},
+			{SourceType: AWSSourceType, Path: "foo"},
+			{SourceType: AzureSourceType, Path: "foo", AzureVaultURL: "https://foo.vault.azure.net/"},
 		},
 	}
 	if err := c.Validate(); err != nil {
@@ -179,3 +190,502 @@ func TestInvalidSourceType(t *testing.T) {
 		t.Fatalf("failed to detect invalid mapping source type")
 	}
 }
+
+func TestRefreshIntervalDefaults(t *testing.T) {
+	c := &Config{}
+	err := yaml.Unmarshal([]byte(`
+refreshInterval: 5m
+mappings:
+  - path: secrets/fast
+    secretName: fast
+    refreshInterval: 30s
+  - path: secrets/slow
+    secretName: slow
+`), c)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	c.SetDefaults()
+
+	if c.RefreshInterval != 5*time.Minute {
+		t.Fatalf("refresh interval should be 5m, is %s", c.RefreshInterval)
+	}
+
+	if c.Mappings[0].RefreshInterval != 30*time.Second {
+		t.Fatalf("mapping refresh interval should be 30s, is %s", c.Mappings[0].RefreshInterval)
+	}
+
+	if c.Mappings[1].RefreshInterval != 5*time.Minute {
+		t.Fatalf("mapping refresh interval should inherit 5m, is %s", c.Mappings[1].RefreshInterval)
+	}
+
+	c = &Config{}
+	c.SetDefaults()
+	if c.RefreshInterval != DefaultRefreshInterval {
+		t.Fatalf("refresh interval should be %s, is %s", DefaultRefreshInterval, c.RefreshInterval)
+	}
+}
+
+func TestInvalidRefreshInterval(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{Path: "foo", RefreshInterval: -time.Second},
+		},
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("failed to detect negative refresh interval")
+	}
+}
+
+func TestAzureDefaults(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{
+				SourceType:    AzureSourceType,
+				Path:          "secret",
+				SecretName:    "secret",
+				AzureVaultURL: "https://foo.vault.azure.net/",
+			},
+			{
+				SourceType:      AzureSourceType,
+				Path:            "cert",
+				SecretName:      "cert",
+				AzureVaultURL:   "https://foo.vault.azure.net/",
+				AzureObjectType: AzureObjectTypeCertificate,
+			},
+		},
+	}
+
+	c.SetDefaults()
+
+	if c.Mappings[0].AzureObjectType != AzureObjectTypeSecret {
+		t.Fatalf("azure object type should default to secret, is %s", c.Mappings[0].AzureObjectType)
+	}
+	if c.Mappings[0].SecretType != corev1.SecretTypeOpaque {
+		t.Fatalf("secret type should be Opaque, is %s", c.Mappings[0].SecretType)
+	}
+	if c.Mappings[1].SecretType != corev1.SecretTypeTLS {
+		t.Fatalf("certificate secret type should be kubernetes.io/tls, is %s", c.Mappings[1].SecretType)
+	}
+}
+
+func TestInvalidAzureMapping(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{SourceType: AzureSourceType, Path: "foo"},
+		},
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("failed to detect missing azure vault url")
+	}
+
+	c = &Config{
+		Mappings: []Mapping{
+			{
+				SourceType:      AzureSourceType,
+				Path:            "foo",
+				AzureVaultURL:   "https://foo.vault.azure.net/",
+				AzureObjectType: "key",
+			},
+		},
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("failed to detect invalid azure object type")
+	}
+}
+
+func TestVaultNamespaceDefaults(t *testing.T) {
+	c := &Config{
+		Vault: VaultConfig{
+			Namespace: "team-a",
+		},
+		Mappings: []Mapping{
+			{
+				Path:       "secrets/foo",
+				SecretName: "foo",
+			},
+			{
+				Path:           "secrets/bar",
+				SecretName:     "bar",
+				VaultNamespace: "team-b",
+			},
+		},
+	}
+
+	c.SetDefaults()
+
+	if c.Vault.AuthNamespace != "team-a" {
+		t.Fatalf("auth namespace should default to the namespace, is %q", c.Vault.AuthNamespace)
+	}
+	if c.Mappings[0].VaultNamespace != "team-a" {
+		t.Fatalf("mapping namespace should default to the namespace, is %q", c.Mappings[0].VaultNamespace)
+	}
+	if c.Mappings[1].VaultNamespace != "team-b" {
+		t.Fatalf("mapping namespace should not be clobbered, is %q", c.Mappings[1].VaultNamespace)
+	}
+
+	c = &Config{
+		Vault: VaultConfig{
+			Namespace:     "admin/team-a",
+			AuthNamespace: "admin",
+		},
+	}
+	c.SetDefaults()
+	if c.Vault.AuthNamespace != "admin" {
+		t.Fatalf("auth namespace should not be clobbered, is %q", c.Vault.AuthNamespace)
+	}
+}
+
+func TestInvalidVaultVersion(t *testing.T) {
+	for name, m := range map[string]Mapping{
+		"negative": {Path: "secrets/data/foo", VaultEngineType: vault.EngineTypeKeyValueV2, VaultVersion: -1},
+		"kv-v1":    {Path: "secrets/foo", VaultEngineType: vault.EngineTypeKeyValueV1, VaultVersion: 1},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid vault version (%s)", name)
+		}
+	}
+
+	for _, engineType := range []vault.EngineType{vault.EngineTypeKeyValueV2, vault.EngineTypeAuto} {
+		c := &Config{Mappings: []Mapping{
+			{Path: "secrets/foo", VaultEngineType: engineType, VaultVersion: 1},
+		}}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("unexpected error for %s: %s", engineType, err)
+		}
+	}
+}
+
+func TestVaultListDefaults(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{Path: "secrets/apps", VaultList: VaultListRecursive},
+			{Path: "secrets/foo", SecretName: "foo"},
+		},
+	}
+	c.SetDefaults()
+
+	if c.Mappings[0].SecretNameTemplate != DefaultSecretNameTemplate {
+		t.Fatalf("secret name template should default to %q, is %q", DefaultSecretNameTemplate, c.Mappings[0].SecretNameTemplate)
+	}
+	if c.Mappings[1].SecretNameTemplate != "" {
+		t.Fatalf("secret name template should only default for vault list mappings, is %q", c.Mappings[1].SecretNameTemplate)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestInvalidVaultList(t *testing.T) {
+	for name, m := range map[string]Mapping{
+		"mode":        {Path: "secrets/apps", VaultList: "sideways"},
+		"source-type": {Path: "secrets/apps", VaultList: VaultListOneLevel, SourceType: GSMSourceType},
+		"secret-name": {Path: "secrets/apps", VaultList: VaultListOneLevel, SecretName: "apps"},
+		"version":     {Path: "secrets/apps", VaultList: VaultListOneLevel, VaultVersion: 2},
+		"template":    {Path: "secrets/apps", VaultList: VaultListOneLevel, SecretNameTemplate: "{{ .Path"},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid vault list mapping (%s)", name)
+		}
+	}
+}
+
+func TestGSMFilterDefaults(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{SourceType: GSMSourceType, Path: "projects/foo", GSMFilter: "labels.k8s-sync=true"},
+		},
+	}
+	c.SetDefaults()
+
+	if c.Mappings[0].Path != "projects/foo" {
+		t.Fatalf("gsm filter mapping path shouldn't get a version suffix, is %q", c.Mappings[0].Path)
+	}
+	if c.Mappings[0].SecretNameTemplate != DefaultSecretNameTemplate {
+		t.Fatalf("secret name template should default to %q, is %q", DefaultSecretNameTemplate, c.Mappings[0].SecretNameTemplate)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestInvalidGSMFilter(t *testing.T) {
+	for name, m := range map[string]Mapping{
+		"source-type": {Path: "projects/foo", GSMFilter: "labels.a=b", SourceType: VaultSourceType},
+		"secret-name": {Path: "projects/foo", GSMFilter: "labels.a=b", SourceType: GSMSourceType, SecretName: "foo"},
+		"secret-path": {Path: "projects/foo/secrets/bar", GSMFilter: "labels.a=b", SourceType: GSMSourceType},
+		"template":    {Path: "projects/foo", GSMFilter: "labels.a=b", SourceType: GSMSourceType, SecretNameTemplate: "{{ .Name"},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid gsm filter mapping (%s)", name)
+		}
+	}
+}
+
+func TestSourcesDefaults(t *testing.T) {
+	c := &Config{
+		Vault: VaultConfig{DefaultEngineType: vault.EngineTypeKeyValueV2},
+		Mappings: []Mapping{
+			{
+				SecretName: "app",
+				Sources: []Mapping{
+					{Path: "secrets/db"},
+					{SourceType: GSMSourceType, Path: "projects/foo/secrets/api-key"},
+				},
+			},
+		},
+	}
+	c.SetDefaults()
+
+	m := c.Mappings[0]
+	if m.SourceType != "" {
+		t.Fatalf("mappings with sources shouldn't get a source type, got %q", m.SourceType)
+	}
+	if m.KeyCollisionPolicy != KeyCollisionError {
+		t.Fatalf("key collision policy should default to %q, is %q", KeyCollisionError, m.KeyCollisionPolicy)
+	}
+	if m.Sources[0].SourceType != VaultSourceType || m.Sources[0].VaultEngineType != vault.EngineTypeKeyValueV2 {
+		t.Fatalf("vault source defaults weren't set: %+v", m.Sources[0])
+	}
+	if m.Sources[1].Path != "projects/foo/secrets/api-key/versions/latest" {
+		t.Fatalf("gsm source path should have the latest suffix, is %q", m.Sources[1].Path)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestInvalidSources(t *testing.T) {
+	source := Mapping{SourceType: VaultSourceType, Path: "secrets/db"}
+	for name, m := range map[string]Mapping{
+		"path":           {SecretName: "app", Path: "secrets/app", Sources: []Mapping{source}},
+		"source-type":    {SecretName: "app", SourceType: GSMSourceType, Sources: []Mapping{source}},
+		"secret-name":    {Sources: []Mapping{source}},
+		"policy":         {SecretName: "app", Sources: []Mapping{source}, KeyCollisionPolicy: "random"},
+		"nested":         {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType, Sources: []Mapping{source}}}},
+		"source-name":    {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType, Path: "a", SecretName: "a"}}},
+		"source-path":    {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType}}},
+		"source-list":    {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType, Path: "a", VaultList: VaultListOneLevel}}},
+		"source-invalid": {SecretName: "app", Sources: []Mapping{{SourceType: "foo", Path: "a"}}},
+		"source-ns":      {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType, Path: "a", Namespaces: Namespaces{Names: []string{"a"}}}}},
+		"source-cluster": {SecretName: "app", Sources: []Mapping{{SourceType: VaultSourceType, Path: "a", Clusters: []string{"a"}}}},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid sources mapping (%s)", name)
+		}
+	}
+}
+
+func TestDuplicateSecretNames(t *testing.T) {
+	c := &Config{
+		Mappings: []Mapping{
+			{Path: "secrets/a", SecretName: "app"},
+			{SourceType: GSMSourceType, Path: "projects/foo/secrets/b", SecretName: "app"},
+		},
+	}
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "use sources to merge them") {
+		t.Fatalf("failed to detect mappings writing to the same secret: %v", err)
+	}
+}
+
+func TestDuplicateSecretNamesInNamespaces(t *testing.T) {
+	c := &Config{
+		Namespace: DefaultNamespace,
+		Mappings: []Mapping{
+			{Path: "secrets/a", SecretName: "app"},
+			{Path: "secrets/b", SecretName: "app", Namespaces: Namespaces{Names: []string{"team-a", "team-b"}}},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("mappings writing to different namespaces should be valid: %s", err)
+	}
+
+	c.Mappings = append(c.Mappings, Mapping{
+		Path:       "secrets/c",
+		SecretName: "app",
+		Namespaces: Namespaces{Names: []string{"team-c", "team-b"}},
+	})
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "kubernetes secret team-b/app") {
+		t.Fatalf("failed to detect mappings writing to the same secret: %v", err)
+	}
+}
+
+func TestNamespacesYAML(t *testing.T) {
+	c := &Config{}
+	err := yaml.Unmarshal([]byte(`
+mappings:
+- path: secrets/a
+  secretName: a
+  namespaces: [team-a, team-b]
+- path: secrets/b
+  secretName: b
+  namespaces:
+    names: [team-a]
+    selector: pentagon=enabled
+`), c)
+	if err != nil {
+		t.Fatalf("unable to parse config: %s", err)
+	}
+
+	if !slices.Equal(c.Mappings[0].Namespaces.Names, []string{"team-a", "team-b"}) ||
+		c.Mappings[0].Namespaces.Selector != "" {
+		t.Fatalf("unexpected namespaces: %+v", c.Mappings[0].Namespaces)
+	}
+	if !slices.Equal(c.Mappings[1].Namespaces.Names, []string{"team-a"}) ||
+		c.Mappings[1].Namespaces.Selector != "pentagon=enabled" {
+		t.Fatalf("unexpected namespaces: %+v", c.Mappings[1].Namespaces)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("namespaces should be valid: %s", err)
+	}
+}
+
+func TestInvalidNamespaces(t *testing.T) {
+	for name, namespaces := range map[string]Namespaces{
+		"name":     {Names: []string{"Team_A"}},
+		"selector": {Selector: "pentagon in (a"},
+	} {
+		c := &Config{Mappings: []Mapping{{Path: "secrets/a", SecretName: "a", Namespaces: namespaces}}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid namespaces (%s)", name)
+		}
+	}
+}
+
+func TestClusterDefaults(t *testing.T) {
+	c := &Config{
+		Namespace: "apps",
+		Clusters: []ClusterConfig{
+			{Name: "east", InCluster: true},
+			{Name: "west", Context: "west", Namespace: "other", Timeout: time.Minute},
+		},
+		Mappings: []Mapping{{Path: "secrets/a", SecretName: "a"}},
+	}
+	c.SetDefaults()
+
+	if c.Clusters[0].Namespace != "apps" || c.Clusters[0].Timeout != DefaultClusterTimeout {
+		t.Fatalf("unexpected defaults: %+v", c.Clusters[0])
+	}
+	if c.Clusters[1].Namespace != "other" || c.Clusters[1].Timeout != time.Minute {
+		t.Fatalf("defaults shouldn't override settings: %+v", c.Clusters[1])
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("clusters should be valid: %s", err)
+	}
+}
+
+func TestInvalidClusters(t *testing.T) {
+	for name, clusters := range map[string][]ClusterConfig{
+		"name":       {{InCluster: true}},
+		"connection": {{Name: "east"}},
+		"both":       {{Name: "east", InCluster: true, Kubeconfig: "/etc/kubeconfig"}},
+		"namespace":  {{Name: "east", InCluster: true, Namespace: "Apps"}},
+		"timeout":    {{Name: "east", InCluster: true, Timeout: -time.Second}},
+		"duplicate":  {{Name: "east", InCluster: true}, {Name: "east", Context: "east"}},
+	} {
+		c := &Config{
+			Clusters: clusters,
+			Mappings: []Mapping{{Path: "secrets/a", SecretName: "a"}},
+		}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid clusters (%s)", name)
+		}
+	}
+}
+
+func TestMappingClusters(t *testing.T) {
+	c := &Config{
+		Namespace: DefaultNamespace,
+		Clusters: []ClusterConfig{
+			{Name: "east", InCluster: true},
+			{Name: "west", Context: "west"},
+		},
+		Mappings: []Mapping{
+			{Path: "secrets/a", SecretName: "app", Clusters: []string{"east"}},
+			{Path: "secrets/b", SecretName: "app", Clusters: []string{"west"}},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("mappings writing to different clusters should be valid: %s", err)
+	}
+
+	c.Mappings = append(c.Mappings, Mapping{Path: "secrets/c", SecretName: "app"})
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "kubernetes secret default/app in cluster east") {
+		t.Fatalf("failed to detect mappings writing to the same secret: %v", err)
+	}
+
+	c.Mappings = []Mapping{{Path: "secrets/a", SecretName: "app", Clusters: []string{"north"}}}
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "unknown cluster") {
+		t.Fatalf("failed to detect an unknown cluster: %v", err)
+	}
+
+	// without clusters, mappings can't select any
+	c.Clusters = nil
+	c.Mappings = []Mapping{{Path: "secrets/a", SecretName: "app", Clusters: []string{""}}}
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "unknown cluster") {
+		t.Fatalf("failed to detect an unknown cluster: %v", err)
+	}
+}
+
+func TestInvalidKeyRules(t *testing.T) {
+	for name, rules := range map[string]KeyRules{
+		"rename": {Rename: map[string]string{"a": "not/valid"}},
+		"prefix": {Prefix: "a b"},
+	} {
+		c := &Config{Mappings: []Mapping{{Path: "foo", Keys: rules}}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid key rules (%s)", name)
+		}
+	}
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	for name, templates := range map[string]map[string]string{
+		"key":      {"not/valid": "{{ .a }}"},
+		"template": {"key": "{{ .a "},
+		"function": {"key": `{{ env "HOME" }}`},
+	} {
+		c := &Config{Mappings: []Mapping{{Path: "foo", Templates: templates}}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid templates (%s)", name)
+		}
+	}
+}
+
+func TestInvalidOutputFormat(t *testing.T) {
+	for name, m := range map[string]Mapping{
+		"format":        {Path: "foo", OutputFormat: "toml"},
+		"key":           {Path: "foo", OutputFormat: OutputFormatJSON, OutputKey: "not/valid"},
+		"key-no-format": {Path: "foo", OutputKey: "config.json"},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid output format (%s)", name)
+		}
+	}
+
+	c := &Config{Mappings: []Mapping{{Path: "foo", OutputFormat: OutputFormatDotenv}}}
+	c.SetDefaults()
+	if c.Mappings[0].OutputKey != ".env" {
+		t.Fatalf("output key should default to .env, is %q", c.Mappings[0].OutputKey)
+	}
+}
+
+func TestInvalidDecode(t *testing.T) {
+	for name, m := range map[string]Mapping{
+		"decode":     {Path: "foo", Decode: "rot13"},
+		"decodeKeys": {Path: "foo", DecodeKeys: map[string]string{"a": "base32"}},
+	} {
+		c := &Config{Mappings: []Mapping{m}}
+		if err := c.Validate(); err == nil {
+			t.Fatalf("failed to detect invalid decoding (%s)", name)
+		}
+	}
+}
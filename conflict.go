@@ -0,0 +1,116 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vimeo/pentagon/metrics"
+)
+
+const (
+	// ConflictPolicyFail fails a mapping whose kubernetes secret already
+	// exists but isn't managed by pentagon (default).
+	ConflictPolicyFail = "fail"
+
+	// ConflictPolicySkip leaves a kubernetes secret that already exists but
+	// isn't managed by pentagon alone, and reports a warning.
+	ConflictPolicySkip = "skip"
+
+	// ConflictPolicyAdopt takes ownership of a kubernetes secret that
+	// already exists but isn't managed by pentagon, recording its previous
+	// owner in the AnnotationPreviousOwner annotation.
+	ConflictPolicyAdopt = "adopt"
+)
+
+// AnnotationPreviousOwner describes who managed a kubernetes secret before
+// pentagon adopted it.
+const AnnotationPreviousOwner = AnnotationPrefix + "previous-owner"
+
+// validConflictPolicies are the valid values of ConflictPolicy.
+var validConflictPolicies = map[string]struct{}{
+	"":                  {},
+	ConflictPolicyFail:  {},
+	ConflictPolicySkip:  {},
+	ConflictPolicyAdopt: {},
+}
+
+// resolveConflict handles secret already existing as existing, without
+// being managed by this pentagon, according to policy.
+func (r *Reflector) resolveConflict(
+	ctx context.Context,
+	c *cluster,
+	policy string,
+	existing *corev1.Secret,
+	secret *corev1.Secret,
+) (Action, error) {
+	owner := previousOwner(existing, r.labelValue)
+	key := c.describe(types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name})
+	switch policy {
+	case ConflictPolicySkip:
+		log.Printf(
+			"WARNING: skipping kubernetes secret %s, which already exists and is managed by %s",
+			key,
+			owner,
+		)
+		return ActionSkipped, nil
+	case ConflictPolicyAdopt:
+		if r.dryRun && !r.serverDryRun {
+			return ActionAdopted, nil
+		}
+
+		// adopting takes ownership of all the fields pentagon sets, even
+		// those that the previous owner manages.
+		adopted := secret.DeepCopy()
+		if adopted.Annotations == nil {
+			adopted.Annotations = map[string]string{}
+		}
+		adopted.Annotations[AnnotationPreviousOwner] = owner
+		if err := r.applySecret(ctx, c, adopted, true); err != nil {
+			r.metrics.Error(metrics.PhaseUpdate, c.Name, secret.Namespace, secret.Name)
+			return ActionFailed, fmt.Errorf("error adopting secret: %s", err)
+		}
+		return ActionAdopted, nil
+	default:
+		r.metrics.Error(metrics.PhaseCreate, c.Name, secret.Namespace, secret.Name)
+		return ActionFailed, fmt.Errorf(
+			"kubernetes secret %s already exists and is managed by %s; set conflictPolicy to %q or %q to resolve this",
+			key,
+			owner,
+			ConflictPolicyAdopt,
+			ConflictPolicySkip,
+		)
+	}
+}
+
+// previousOwner describes who manages existing, which isn't managed by the
+// pentagon with labelValue: another pentagon, the owners in its owner
+// references or the field managers that last wrote it.
+func previousOwner(existing *corev1.Secret, labelValue string) string {
+	if value, ok := existing.Labels[LabelKey]; ok && value != labelValue {
+		return fmt.Sprintf("pentagon (label %s=%s)", LabelKey, value)
+	}
+
+	var owners []string
+	for _, ref := range existing.OwnerReferences {
+		owners = append(owners, ref.Kind+"/"+ref.Name)
+	}
+	if len(owners) > 0 {
+		return strings.Join(owners, ", ")
+	}
+
+	for _, field := range existing.ManagedFields {
+		if field.Manager != "" && !slices.Contains(owners, field.Manager) {
+			owners = append(owners, field.Manager)
+		}
+	}
+	if len(owners) > 0 {
+		return strings.Join(owners, ", ")
+	}
+	return "unknown"
+}
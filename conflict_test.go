@@ -0,0 +1,305 @@
+package pentagon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestReflectorConflictFail(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset()
+	_, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Labels:      map[string]string{"app": "legacy"},
+			Annotations: map[string]string{"note": "made by hand"},
+		},
+		Data: map[string][]byte{"password": []byte("old")},
+	}, metav1.CreateOptions{FieldManager: "kubectl-create"})
+	if err != nil {
+		t.Fatalf("unable to create secret: %s", err)
+	}
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"password": "new"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+	mapping := Mapping{
+		SourceType:      VaultSourceType,
+		Path:            "secrets/foo",
+		SecretName:      "foo",
+		VaultEngineType: vault.EngineTypeKeyValueV1,
+	}
+
+	for _, policy := range []string{"", ConflictPolicyFail} {
+		mapping.ConflictPolicy = policy
+		results, err := r.ReflectResults(ctx, []Mapping{mapping})
+		if err == nil || !strings.Contains(err.Error(), "already exists and is managed by kubectl-create") {
+			t.Fatalf("%q: expected a conflict error, got %v", policy, err)
+		}
+		if results[0].Action != ActionFailed {
+			t.Fatalf("%q: unexpected result: %+v", policy, results[0])
+		}
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should still be there: %s", err)
+	}
+	if string(secret.Data["password"]) != "old" {
+		t.Fatalf("secret shouldn't have been changed: %v", secret.Data)
+	}
+}
+
+func TestReflectorConflictSkip(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset()
+	_, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Labels:      map[string]string{"app": "legacy"},
+			Annotations: map[string]string{"note": "made by hand"},
+		},
+		Data: map[string][]byte{"password": []byte("old")},
+	}, metav1.CreateOptions{FieldManager: "kubectl-create"})
+	if err != nil {
+		t.Fatalf("unable to create secret: %s", err)
+	}
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"password": "new"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+	mapping := Mapping{
+		SourceType:      VaultSourceType,
+		Path:            "secrets/foo",
+		SecretName:      "foo",
+		VaultEngineType: vault.EngineTypeKeyValueV1,
+		ConflictPolicy:  ConflictPolicySkip,
+	}
+
+	results, err := r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("skipping shouldn't be an error: %s", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionSkipped || results[0].Warning == "" {
+		t.Fatalf("expected a skipped result with a warning: %+v", results)
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should still be there: %s", err)
+	}
+	if string(secret.Data["password"]) != "old" || secret.Labels[LabelKey] != "" {
+		t.Fatalf("secret shouldn't have been changed: %+v", secret)
+	}
+}
+
+func TestReflectorConflictAdopt(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset()
+	_, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Labels:      map[string]string{"app": "legacy"},
+			Annotations: map[string]string{"note": "made by hand"},
+		},
+		Data: map[string][]byte{"password": []byte("old")},
+	}, metav1.CreateOptions{FieldManager: "kubectl-create"})
+	if err != nil {
+		t.Fatalf("unable to create secret: %s", err)
+	}
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"password": "new"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+	mapping := Mapping{
+		SourceType:      VaultSourceType,
+		Path:            "secrets/foo",
+		SecretName:      "foo",
+		VaultEngineType: vault.EngineTypeKeyValueV1,
+		ConflictPolicy:  ConflictPolicyAdopt,
+	}
+
+	results, err := r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionAdopted {
+		t.Fatalf("secret should have been adopted: %+v", results[0])
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	checkAdopted := func() {
+		t.Helper()
+		secret, err := secrets.Get(ctx, "foo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret should be there: %s", err)
+		}
+		if string(secret.Data["password"]) != "new" {
+			t.Fatalf("secret should have the new password: %v", secret.Data)
+		}
+		if secret.Labels[LabelKey] != "test" {
+			t.Fatalf("secret should have the pentagon label: %v", secret.Labels)
+		}
+		if secret.Annotations[AnnotationPreviousOwner] != "kubectl-create" {
+			t.Fatalf("secret should record its previous owner: %v", secret.Annotations)
+		}
+	}
+	checkAdopted()
+
+	// once adopted, the secret is managed like any other, keeping its
+	// previous owner
+	results, err = r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUnchanged {
+		t.Fatalf("adopted secret should be unchanged: %+v", results[0])
+	}
+	checkAdopted()
+}
+
+func TestReflectorConflictDryRun(t *testing.T) {
+	ctx := context.Background()
+	for policy, action := range map[string]Action{
+		ConflictPolicyFail:  ActionFailed,
+		ConflictPolicySkip:  ActionSkipped,
+		ConflictPolicyAdopt: ActionAdopted,
+	} {
+		k8sClient := k8sfake.NewClientset()
+		_, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "foo",
+				Labels:      map[string]string{"app": "legacy"},
+				Annotations: map[string]string{"note": "made by hand"},
+			},
+			Data: map[string][]byte{"password": []byte("old")},
+		}, metav1.CreateOptions{FieldManager: "kubectl-create"})
+		if err != nil {
+			t.Fatalf("unable to create secret: %s", err)
+		}
+
+		vaultClient := vault.NewMock(map[string]vault.EngineType{
+			"secrets": vault.EngineTypeKeyValueV1,
+		})
+		vaultClient.Write("secrets/foo", map[string]any{"password": "new"})
+
+		r := NewReflector(
+			vaultClient,
+			gsm.NewMockGSM(nil),
+			k8sClient, DefaultNamespace,
+			"test",
+			WithDryRun(false),
+			WithContinueOnError(),
+		)
+		mapping := Mapping{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "foo",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			ConflictPolicy:  policy,
+		}
+
+		results, _ := r.ReflectResults(ctx, []Mapping{mapping})
+		if results[0].Action != action {
+			t.Fatalf("%s: expected %s, got %+v", policy, action, results[0])
+		}
+
+		secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "foo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret should still be there: %s", err)
+		}
+		if string(secret.Data["password"]) != "old" {
+			t.Fatalf("%s: dry run shouldn't change the secret: %v", policy, secret.Data)
+		}
+	}
+}
+
+func TestPreviousOwner(t *testing.T) {
+	for expected, secret := range map[string]*corev1.Secret{
+		"pentagon (label pentagon=other)": {ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{LabelKey: "other"},
+		}},
+		"Certificate/foo": {ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Certificate", Name: "foo"}},
+		}},
+		"kubectl, helm": {ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl"},
+				{Manager: "helm"},
+				{Manager: "kubectl"},
+			},
+		}},
+		"unknown": {},
+	} {
+		if owner := previousOwner(secret, "test"); owner != expected {
+			t.Errorf("expected %q, got %q", expected, owner)
+		}
+	}
+}
+
+func TestConflictPolicyDefaults(t *testing.T) {
+	c := &Config{
+		ConflictPolicy: ConflictPolicyAdopt,
+		Mappings: []Mapping{
+			{Path: "a", SecretName: "a"},
+			{Path: "b", SecretName: "b", ConflictPolicy: ConflictPolicySkip},
+		},
+	}
+	c.SetDefaults()
+	if c.Mappings[0].ConflictPolicy != ConflictPolicyAdopt || c.Mappings[1].ConflictPolicy != ConflictPolicySkip {
+		t.Fatalf("unexpected conflict policies: %q, %q", c.Mappings[0].ConflictPolicy, c.Mappings[1].ConflictPolicy)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c = &Config{}
+	c.SetDefaults()
+	if c.ConflictPolicy != ConflictPolicyFail {
+		t.Fatalf("conflict policy should default to %q, is %q", ConflictPolicyFail, c.ConflictPolicy)
+	}
+
+	for _, c := range []*Config{
+		{ConflictPolicy: "steal", Mappings: []Mapping{{Path: "a"}}},
+		{Mappings: []Mapping{{Path: "a", ConflictPolicy: "steal"}}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatal("failed to detect invalid conflict policy")
+		}
+	}
+}
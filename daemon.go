@@ -0,0 +1,98 @@
+package pentagon
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run keeps reflecting mappings until ctx is cancelled.  Every mapping is
+// reflected immediately, and after that each one is reflected again once its
+// RefreshInterval has elapsed.  Mappings without a RefreshInterval use
+// defaultInterval.  Errors are logged rather than returned so that a single
+// failed run doesn't stop future ones.  A failing mapping never stops the
+// others, as if WithContinueOnError was set, and is retried after
+// DefaultRetryInterval, backing off to its refresh interval while it keeps
+// failing.
+func (r *Reflector) Run(ctx context.Context, mappings []Mapping, defaultInterval time.Duration) {
+	if len(mappings) == 0 {
+		<-ctx.Done()
+		return
+	}
+	if defaultInterval <= 0 {
+		defaultInterval = DefaultRefreshInterval
+	}
+
+	// the zero time means that every mapping is due on the first pass
+	nextRun := make([]time.Time, len(mappings))
+	// failures counts the consecutive failures of each mapping
+	failures := make([]int, len(mappings))
+
+	for {
+		now := time.Now()
+		due := make([]Mapping, 0, len(mappings))
+		dueIndexes := make([]int, 0, len(mappings))
+		for i, mapping := range mappings {
+			if now.Before(nextRun[i]) {
+				continue
+			}
+			due = append(due, mapping)
+			dueIndexes = append(dueIndexes, i)
+		}
+
+		if len(due) > 0 {
+			_, failed, err := r.reflect(ctx, due, mappings, true)
+			if err != nil {
+				log.Printf("error reflecting secrets into kubernetes: %s", err)
+			}
+			for j, i := range dueIndexes {
+				interval := refreshInterval(mappings[i], defaultInterval)
+				if failed[j] {
+					failures[i]++
+					interval = retryInterval(r.retryInterval, failures[i], interval)
+				} else {
+					failures[i] = 0
+				}
+				nextRun[i] = now.Add(interval)
+			}
+		}
+
+		timer := time.NewTimer(time.Until(earliest(nextRun)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshInterval returns the interval at which mapping should be reflected.
+func refreshInterval(mapping Mapping, defaultInterval time.Duration) time.Duration {
+	if mapping.RefreshInterval > 0 {
+		return mapping.RefreshInterval
+	}
+	return defaultInterval
+}
+
+// retryInterval returns how long to wait before retrying a mapping that has
+// failed failures times in a row.  It starts at base and doubles with each
+// failure, but is never longer than the mapping's refresh interval.
+func retryInterval(base time.Duration, failures int, interval time.Duration) time.Duration {
+	retry := base
+	for i := 1; i < failures && retry < interval; i++ {
+		retry *= 2
+	}
+	return min(retry, interval)
+}
+
+// earliest returns the earliest of times.
+func earliest(times []time.Time) time.Time {
+	var first time.Time
+	for i, t := range times {
+		if i == 0 || t.Before(first) {
+			first = t
+		}
+	}
+	return first
+}
@@ -0,0 +1,188 @@
+package pentagon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestRunRefreshIntervals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8sfake.NewClientset()
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/fast", map[string]any{"value": "1"})
+	vaultClient.Write("secrets/slow", map[string]any{"value": "1"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient,
+		DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	mappings := []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/fast",
+			SecretName:      "fast",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			RefreshInterval: 10 * time.Millisecond,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/slow",
+			SecretName:      "slow",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx, mappings, time.Hour)
+	}()
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	waitForValue := func(name, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+			if err == nil && string(secret.Data["value"]) == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("secret %s never had value %q", name, want)
+	}
+
+	// both mappings are reflected on the first pass
+	waitForValue("fast", "1")
+	waitForValue("slow", "1")
+
+	vaultClient.Write("secrets/fast", map[string]any{"value": "2"})
+	vaultClient.Write("secrets/slow", map[string]any{"value": "2"})
+
+	// only the fast mapping should have been refreshed
+	waitForValue("fast", "2")
+	secret, err := secrets.Get(ctx, "slow", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("slow should be there: %s", err)
+	}
+	if string(secret.Data["value"]) != "1" {
+		t.Fatalf("slow should not have been refreshed yet: %s", secret.Data["value"])
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after context cancellation")
+	}
+}
+
+func TestRunRetriesFailedMappings(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8sfake.NewClientset()
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/ok", map[string]any{"value": "1"})
+
+	// without WithContinueOnError, which Run doesn't need
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient,
+		DefaultNamespace,
+		DefaultLabelValue,
+	)
+	r.retryInterval = 10 * time.Millisecond
+
+	// broken fails until its vault secret is written
+	mappings := []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/broken",
+			SecretName:      "broken",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/ok",
+			SecretName:      "ok",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx, mappings, time.Hour)
+	}()
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	waitForSecret := func(name string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := secrets.Get(ctx, name, metav1.GetOptions{}); err == nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("secret %s was never written", name)
+	}
+
+	// the failure of broken doesn't keep ok from being reflected
+	waitForSecret("ok")
+
+	// broken is retried long before its hourly refresh
+	vaultClient.Write("secrets/broken", map[string]any{"value": "1"})
+	waitForSecret("broken")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after context cancellation")
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	if d := refreshInterval(Mapping{}, time.Minute); d != time.Minute {
+		t.Errorf("expected default interval, got %s", d)
+	}
+	if d := refreshInterval(Mapping{RefreshInterval: time.Second}, time.Minute); d != time.Second {
+		t.Errorf("expected mapping interval, got %s", d)
+	}
+}
+
+func TestRetryInterval(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, time.Minute},
+	} {
+		if d := retryInterval(time.Second, tc.failures, time.Minute); d != tc.want {
+			t.Errorf("expected %s after %d failures, got %s", tc.want, tc.failures, d)
+		}
+	}
+}
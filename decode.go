@@ -0,0 +1,107 @@
+package pentagon
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// DecodeBase64 decodes values encoded with standard, padded base64.
+	DecodeBase64 = "base64"
+
+	// DecodeBase64URL decodes values encoded with URL-safe base64, with or
+	// without padding.
+	DecodeBase64URL = "base64url"
+
+	// DecodeHex decodes hex-encoded values.
+	DecodeHex = "hex"
+)
+
+// validDecodings are the valid values of Decode and DecodeKeys.
+var validDecodings = map[string]struct{}{
+	DecodeBase64:    {},
+	DecodeBase64URL: {},
+	DecodeHex:       {},
+}
+
+// validateDecode validates the Decode settings of m.
+func validateDecode(m Mapping) error {
+	if m.Decode != "" {
+		if _, ok := validDecodings[m.Decode]; !ok {
+			return fmt.Errorf("invalid decoding: %+v", m.Decode)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(m.DecodeKeys)) {
+		if _, ok := validDecodings[m.DecodeKeys[k]]; !ok {
+			return fmt.Errorf("invalid decoding for key %q: %+v", k, m.DecodeKeys[k])
+		}
+	}
+	return nil
+}
+
+// decodeData decodes the values of data with mapping's Decode, or the
+// decoding of each key in its DecodeKeys.  Keys in DecodeKeys have to be in
+// data, since a typo would otherwise leave a value encoded.
+func decodeData(mapping Mapping, data map[string][]byte) (map[string][]byte, error) {
+	if mapping.Decode == "" && len(mapping.DecodeKeys) == 0 {
+		return data, nil
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(mapping.DecodeKeys)) {
+		if _, ok := data[k]; !ok {
+			return nil, fmt.Errorf("key %q to decode as %s is missing", k, mapping.DecodeKeys[k])
+		}
+	}
+
+	decoded := make(map[string][]byte, len(data))
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		decoding := mapping.Decode
+		if d, ok := mapping.DecodeKeys[k]; ok {
+			decoding = d
+		}
+		if decoding == "" {
+			decoded[k] = data[k]
+			continue
+		}
+
+		value, err := decodeValue(decoding, data[k])
+		if err != nil {
+			return nil, fmt.Errorf("key %q isn't valid %s: %s", k, decoding, err)
+		}
+		decoded[k] = value
+	}
+	return decoded, nil
+}
+
+// decodeValue decodes value with decoding.  Surrounding whitespace is
+// ignored, as are line breaks within base64.
+func decodeValue(decoding string, value []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(value))
+
+	var decoded []byte
+	var err error
+	switch decoding {
+	case DecodeBase64:
+		decoded, err = base64.StdEncoding.DecodeString(s)
+	case DecodeBase64URL:
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case DecodeHex:
+		decoded, err = hex.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown decoding %q", decoding)
+	}
+	if err != nil {
+		// the errors of the encoding packages can include the invalid
+		// byte, so only the type of error is reported
+		switch err.(type) {
+		case base64.CorruptInputError, hex.InvalidByteError:
+			return nil, fmt.Errorf("invalid character")
+		}
+		return nil, fmt.Errorf("invalid length")
+	}
+	return decoded, nil
+}
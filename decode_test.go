@@ -0,0 +1,141 @@
+package pentagon
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestDecodeValue(t *testing.T) {
+	binary := []byte{0x00, 0xfb, 0xff, 0x10}
+	for _, test := range []struct {
+		decoding string
+		value    string
+	}{
+		{DecodeBase64, "APv/EA=="},
+		{DecodeBase64, " APv/\nEA==\n"},
+		{DecodeBase64URL, "APv_EA=="},
+		{DecodeBase64URL, "APv_EA"},
+		{DecodeHex, "00fbff10"},
+		{DecodeHex, "00FBFF10\n"},
+	} {
+		decoded, err := decodeValue(test.decoding, []byte(test.value))
+		if err != nil {
+			t.Fatalf("%s %q: unexpected error: %s", test.decoding, test.value, err)
+		}
+		if !bytes.Equal(decoded, binary) {
+			t.Errorf("%s %q: expected %x, got %x", test.decoding, test.value, binary, decoded)
+		}
+	}
+
+	for _, test := range []struct {
+		decoding string
+		value    string
+	}{
+		{DecodeBase64, "s3cr3t!"},
+		{DecodeBase64, "APv_EA=="},
+		{DecodeBase64URL, "s3cr3t!"},
+		{DecodeHex, "s3cr3t"},
+		{DecodeHex, "abc"},
+	} {
+		_, err := decodeValue(test.decoding, []byte(test.value))
+		if err == nil {
+			t.Fatalf("%s %q: expected an error", test.decoding, test.value)
+		}
+		if strings.Contains(err.Error(), "s3cr3t") || strings.Contains(err.Error(), "!") {
+			t.Fatalf("%s %q: error reveals the value: %s", test.decoding, test.value, err)
+		}
+	}
+}
+
+func TestDecodeData(t *testing.T) {
+	data := map[string][]byte{
+		"keystore.p12": []byte("AAEC"),
+		"cert.der":     []byte("000102"),
+		"password":     []byte("hunter2"),
+	}
+
+	decoded, err := decodeData(Mapping{
+		DecodeKeys: map[string]string{
+			"keystore.p12": DecodeBase64,
+			"cert.der":     DecodeHex,
+		},
+	}, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for k, expected := range map[string][]byte{
+		"keystore.p12": {0, 1, 2},
+		"cert.der":     {0, 1, 2},
+		"password":     []byte("hunter2"),
+	} {
+		if !bytes.Equal(decoded[k], expected) {
+			t.Errorf("%s: expected %x, got %x", k, expected, decoded[k])
+		}
+	}
+
+	// decoding every key fails on the password, without revealing it
+	_, err = decodeData(Mapping{Decode: DecodeHex}, data)
+	if err == nil || !strings.Contains(err.Error(), `key "password" isn't valid hex`) {
+		t.Fatalf("expected a per-key error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("error reveals the value: %s", err)
+	}
+
+	_, err = decodeData(Mapping{DecodeKeys: map[string]string{"missing": DecodeHex}}, data)
+	if err == nil {
+		t.Fatal("expected an error decoding a missing key")
+	}
+}
+
+func TestReflectorDecode(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/keystore", map[string]any{
+		"keystore.jks": "/u3+7QAAAAI=",
+		"password":     "changeit",
+	})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/keystore",
+			SecretName:      "keystore",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			DecodeKeys:      map[string]string{"keystore.jks": DecodeBase64},
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "keystore", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if !bytes.Equal(secret.Data["keystore.jks"], []byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 2}) {
+		t.Fatalf("keystore should have been decoded, is %x", secret.Data["keystore.jks"])
+	}
+	if string(secret.Data["password"]) != "changeit" {
+		t.Fatalf("password shouldn't have been decoded, is %q", secret.Data["password"])
+	}
+}
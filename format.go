@@ -0,0 +1,160 @@
+package pentagon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// OutputFormatDotenv writes a secret's keys as KEY="value" lines.
+	OutputFormatDotenv = "dotenv"
+
+	// OutputFormatJSON writes a secret's keys as a JSON object.
+	OutputFormatJSON = "json"
+
+	// OutputFormatYAML writes a secret's keys as a YAML mapping.
+	OutputFormatYAML = "yaml"
+
+	// OutputFormatProperties writes a secret's keys as a Java properties
+	// file.
+	OutputFormatProperties = "properties"
+)
+
+// defaultOutputKeys are the keys that each output format is written to if a
+// mapping doesn't set OutputKey.
+var defaultOutputKeys = map[string]string{
+	OutputFormatDotenv:     ".env",
+	OutputFormatJSON:       "secrets.json",
+	OutputFormatYAML:       "secrets.yaml",
+	OutputFormatProperties: "application.properties",
+}
+
+// dotenvKey matches the keys that can be written to a dotenv file, which are
+// the valid names of environment variables.
+var dotenvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatData serializes data in format.  Keys are always written in sorted
+// order, so that the result only changes when data does.  Binary values can't
+// be written to any of the formats, so a value that isn't UTF-8 fails with
+// the name of its key.
+func formatData(format string, data map[string][]byte) ([]byte, error) {
+	keys := slices.Sorted(maps.Keys(data))
+	for _, k := range keys {
+		if !utf8.Valid(data[k]) {
+			return nil, fmt.Errorf("the value of key %q isn't valid UTF-8, so it can't be written as %s", k, format)
+		}
+	}
+
+	switch format {
+	case OutputFormatDotenv:
+		return formatDotenv(keys, data)
+	case OutputFormatJSON:
+		// encoding/json sorts the keys of maps
+		values := make(map[string]string, len(data))
+		for k, v := range data {
+			values[k] = string(v)
+		}
+		encoded, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding JSON: %s", err)
+		}
+		return append(encoded, '\n'), nil
+	case OutputFormatYAML:
+		values := make(yaml.MapSlice, 0, len(keys))
+		for _, k := range keys {
+			values = append(values, yaml.MapItem{Key: k, Value: string(data[k])})
+		}
+		encoded, err := yaml.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding YAML: %s", err)
+		}
+		return encoded, nil
+	case OutputFormatProperties:
+		return formatProperties(keys, data), nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %q", format)
+	}
+}
+
+// formatDotenv writes each key as KEY="value", escaping backslashes, quotes,
+// dollar signs and newlines within the double quotes.
+func formatDotenv(keys []string, data map[string][]byte) ([]byte, error) {
+	escaper := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"\n", `\n`,
+		"\r", `\r`,
+	)
+
+	var b bytes.Buffer
+	for _, k := range keys {
+		if !dotenvKey.MatchString(k) {
+			return nil, fmt.Errorf("key %q isn't a valid environment variable name", k)
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"\n", k, escaper.Replace(string(data[k])))
+	}
+	return b.Bytes(), nil
+}
+
+// formatProperties writes each key as key=value, escaped like
+// java.util.Properties.store does so that the file can be loaded as
+// ISO-8859-1.
+func formatProperties(keys []string, data map[string][]byte) []byte {
+	var b bytes.Buffer
+	for _, k := range keys {
+		b.WriteString(escapeProperty(k, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(string(data[k]), false))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// escapeProperty escapes s as a key or value of a properties file.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case ' ':
+			// spaces separate keys from values, and leading spaces of
+			// values are skipped
+			if isKey || i == 0 {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteRune(r)
+			}
+		case '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\u%04x`, u)
+				}
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
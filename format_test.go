@@ -0,0 +1,163 @@
+package pentagon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestFormatData(t *testing.T) {
+	data := map[string][]byte{
+		"PASSWORD": []byte(`p@ss "word" $HOME \ end`),
+		"MULTI":    []byte("line1\nline2"),
+		"BOOL":     []byte("true"),
+		"EMPTY":    []byte(""),
+	}
+
+	for format, expected := range map[string]string{
+		OutputFormatDotenv: `BOOL="true"
+EMPTY=""
+MULTI="line1\nline2"
+PASSWORD="p@ss \"word\" \$HOME \\ end"
+`,
+		OutputFormatJSON: `{
+  "BOOL": "true",
+  "EMPTY": "",
+  "MULTI": "line1\nline2",
+  "PASSWORD": "p@ss \"word\" $HOME \\ end"
+}
+`,
+		OutputFormatYAML: `BOOL: "true"
+EMPTY: ""
+MULTI: |-
+  line1
+  line2
+PASSWORD: p@ss "word" $HOME \ end
+`,
+		OutputFormatProperties: `BOOL=true
+EMPTY=
+MULTI=line1\nline2
+PASSWORD=p@ss "word" $HOME \\ end
+`,
+	} {
+		formatted, err := formatData(format, data)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", format, err)
+		}
+		if string(formatted) != expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", format, expected, formatted)
+		}
+	}
+
+	// YAML round-trips the values
+	formatted, err := formatData(OutputFormatYAML, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded := map[string]string{}
+	if err := yaml.Unmarshal(formatted, &decoded); err != nil {
+		t.Fatalf("error decoding YAML: %s", err)
+	}
+	for k, v := range data {
+		if decoded[k] != string(v) {
+			t.Fatalf("YAML value of %s should be %q, is %q", k, v, decoded[k])
+		}
+	}
+
+	// dotenv keys have to be environment variable names
+	_, err = formatData(OutputFormatDotenv, map[string][]byte{"tls.crt": []byte("secret")})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("expected an invalid key error without the value, got %v", err)
+	}
+
+	// binary values can't be formatted
+	_, err = formatData(OutputFormatJSON, map[string][]byte{"key": {0xff, 0xfe}})
+	if err == nil {
+		t.Fatal("expected an invalid UTF-8 error")
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	for in, expected := range map[string][2]string{
+		"a b":      {`a\ b`, `a b`},
+		" lead":    {`\ lead`, `\ lead`},
+		"k=v:w":    {`k\=v\:w`, `k\=v\:w`},
+		"#!":       {`\#\!`, `\#\!`},
+		"tab\t":    {`tab\t`, `tab\t`},
+		"café":     {`caf\u00e9`, `caf\u00e9`},
+		"key🔑":     {`key\ud83d\udd11`, `key\ud83d\udd11`},
+		"a\\b\r\f": {`a\\b\r\f`, `a\\b\r\f`},
+	} {
+		if key := escapeProperty(in, true); key != expected[0] {
+			t.Errorf("key %q: expected %q, got %q", in, expected[0], key)
+		}
+		if value := escapeProperty(in, false); value != expected[1] {
+			t.Errorf("value %q: expected %q, got %q", in, expected[1], value)
+		}
+	}
+}
+
+func TestReflectorOutputFormat(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/app", map[string]any{
+		"db.user":     "app",
+		"db.password": "hunter2",
+	})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	mappings := []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/app",
+			SecretName:      "app",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			OutputFormat:    OutputFormatProperties,
+		},
+	}
+	results, err := r.ReflectResults(ctx, mappings)
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionCreated {
+		t.Fatalf("secret should have been created: %+v", results[0])
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if len(secret.Data) != 1 {
+		t.Fatalf("secret should only have one key: %v", secret.Data)
+	}
+	expected := "db.password=hunter2\ndb.user=app\n"
+	if string(secret.Data["application.properties"]) != expected {
+		t.Fatalf("expected %q, got %q", expected, secret.Data["application.properties"])
+	}
+
+	// the ordering is stable, so reflecting again doesn't change anything
+	results, err = r.ReflectResults(ctx, mappings)
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUnchanged {
+		t.Fatalf("secret should have been unchanged: %+v", results[0])
+	}
+}
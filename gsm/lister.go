@@ -0,0 +1,51 @@
+package gsm
+
+import (
+	"context"
+	"fmt"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/iterator"
+)
+
+// SecretLister lists the secrets in a GSM project.
+type SecretLister interface {
+	// ListSecrets returns the secrets under parent (projects/* or
+	// projects/*/locations/*) that match filter, which uses GSM's list
+	// filter syntax, e.g. "labels.k8s-sync=true".
+	ListSecrets(ctx context.Context, parent, filter string) ([]*secretmanagerpb.Secret, error)
+}
+
+// NewClientLister returns a SecretLister that lists secrets with client.
+func NewClientLister(client *secretmanager.Client) SecretLister {
+	return &clientLister{client: client}
+}
+
+type clientLister struct {
+	client *secretmanager.Client
+}
+
+// ListSecrets lists the secrets under parent that match filter, going
+// through all the pages of results.
+func (c *clientLister) ListSecrets(
+	ctx context.Context,
+	parent, filter string,
+) ([]*secretmanagerpb.Secret, error) {
+	it := c.client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{
+		Parent: parent,
+		Filter: filter,
+	})
+
+	var secrets []*secretmanagerpb.Secret
+	for {
+		secret, err := it.Next()
+		if err == iterator.Done {
+			return secrets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing secrets in %s: %s", parent, err)
+		}
+		secrets = append(secrets, secret)
+	}
+}
@@ -3,6 +3,9 @@ package gsm
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/googleapis/gax-go/v2"
@@ -19,6 +22,10 @@ type SecretAccessor interface {
 
 type MockGSM struct {
 	Data map[string][]byte
+
+	// Labels are the labels of secrets, by secret name (e.g.
+	// projects/foo/secrets/bar), for ListSecrets.
+	Labels map[string]map[string]string
 }
 
 func NewMockGSM(data map[string][]byte) *MockGSM {
@@ -43,3 +50,95 @@ func (m *MockGSM) AccessSecretVersion(
 		},
 	}, nil
 }
+
+// ListSecrets lists the secrets under parent in the mock that match filter.
+// The secrets are those with versions in Data, plus those with Labels.  Only
+// filters on labels are supported: terms of the form "labels.key=value",
+// "labels.key:value" or "labels.key:*", separated by spaces or "AND".
+func (m *MockGSM) ListSecrets(
+	ctx context.Context,
+	parent, filter string,
+) ([]*secretmanagerpb.Secret, error) {
+	terms, err := parseMockFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+	for name := range m.Data {
+		if i := strings.Index(name, "/versions/"); i >= 0 {
+			name = name[:i]
+		}
+		names[name] = struct{}{}
+	}
+	for name := range m.Labels {
+		names[name] = struct{}{}
+	}
+
+	var secrets []*secretmanagerpb.Secret
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		id, ok := strings.CutPrefix(name, parent+"/secrets/")
+		if !ok || strings.Contains(id, "/") {
+			continue
+		}
+
+		labels := m.Labels[name]
+		if !matchesMockFilter(labels, terms) {
+			continue
+		}
+		secrets = append(secrets, &secretmanagerpb.Secret{
+			Name:   name,
+			Labels: maps.Clone(labels),
+		})
+	}
+	return secrets, nil
+}
+
+// mockFilterTerm is a term of a filter passed to MockGSM.ListSecrets.  An
+// empty value with exists set matches any value.
+type mockFilterTerm struct {
+	key    string
+	value  string
+	exists bool
+}
+
+// parseMockFilter parses the subset of GSM's filter syntax supported by
+// MockGSM.
+func parseMockFilter(filter string) ([]mockFilterTerm, error) {
+	var terms []mockFilterTerm
+	for _, field := range strings.Fields(filter) {
+		if field == "AND" {
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(field, "labels.")
+		if !ok {
+			return nil, fmt.Errorf("unsupported filter term %q", field)
+		}
+		if key, value, ok := strings.Cut(rest, "="); ok {
+			terms = append(terms, mockFilterTerm{key: key, value: value})
+			continue
+		}
+		if key, value, ok := strings.Cut(rest, ":"); ok {
+			if value == "*" {
+				terms = append(terms, mockFilterTerm{key: key, exists: true})
+			} else {
+				terms = append(terms, mockFilterTerm{key: key, value: value})
+			}
+			continue
+		}
+		return nil, fmt.Errorf("unsupported filter term %q", field)
+	}
+	return terms, nil
+}
+
+// matchesMockFilter returns true if labels match all of terms.
+func matchesMockFilter(labels map[string]string, terms []mockFilterTerm) bool {
+	for _, term := range terms {
+		value, ok := labels[term.key]
+		if !ok || (!term.exists && value != term.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package gsm
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"testing"
 
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
@@ -38,3 +40,42 @@ func TestMockGSM(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMockGSMListSecrets(t *testing.T) {
+	m := NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/a/versions/latest": []byte("a"),
+		"projects/foo/secrets/b/versions/1":      []byte("b"),
+		"projects/bar/secrets/c/versions/latest": []byte("c"),
+	})
+	m.Labels = map[string]map[string]string{
+		"projects/foo/secrets/a": {"k8s-sync": "true", "team": "x"},
+		"projects/foo/secrets/b": {"k8s-sync": "false"},
+		"projects/foo/secrets/d": {"team": "x"},
+	}
+	ctx := context.Background()
+
+	for filter, expected := range map[string][]string{
+		"":                                    {"a", "b", "d"},
+		"labels.k8s-sync=true":                {"a"},
+		"labels.k8s-sync:*":                   {"a", "b"},
+		"labels.team:x":                       {"a", "d"},
+		"labels.team=x AND labels.k8s-sync:*": {"a"},
+		"labels.missing=true":                 nil,
+	} {
+		secrets, err := m.ListSecrets(ctx, "projects/foo", filter)
+		if err != nil {
+			t.Fatalf("%q: %s", filter, err)
+		}
+		var ids []string
+		for _, s := range secrets {
+			ids = append(ids, strings.TrimPrefix(s.Name, "projects/foo/secrets/"))
+		}
+		if !slices.Equal(ids, expected) {
+			t.Errorf("%q: expected %v, got %v", filter, expected, ids)
+		}
+	}
+
+	if _, err := m.ListSecrets(ctx, "projects/foo", "name:a"); err == nil {
+		t.Fatal("expected an error for an unsupported filter")
+	}
+}
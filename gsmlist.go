@@ -0,0 +1,53 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"path"
+)
+
+// AnnotationGSMFilterPath is the project of the GSMFilter mapping that a
+// secret was generated from.  Like AnnotationVaultListPath, it keeps the
+// secrets generated from the mapping when the project can't be listed.
+const AnnotationGSMFilterPath = AnnotationPrefix + "gsm-filter-path"
+
+// expandGSMFilter lists the GSM secrets matching the filter of a GSMFilter
+// mapping, and returns a mapping for the latest version of each of them.
+func (r *Reflector) expandGSMFilter(ctx context.Context, mapping Mapping) ([]Mapping, error) {
+	if r.gsmLister == nil {
+		return nil, fmt.Errorf("no GSM secret lister configured for %s", mapping.Path)
+	}
+
+	namer, err := newSecretNamer(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := r.gsmLister.ListSecrets(ctx, mapping.Path, mapping.GSMFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make([]Mapping, 0, len(secrets))
+	for _, secret := range secrets {
+		id := path.Base(secret.Name)
+		name, err := namer.name("gsm secret "+secret.Name, secretNameData{
+			Path:   id,
+			Name:   id,
+			Labels: secret.Labels,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		m := mapping
+		m.Path = secret.Name + gsmLatestSuffix
+		m.SecretName = name
+		m.GSMFilter = ""
+		m.SecretNameTemplate = ""
+		m.listPath = mapping.Path
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
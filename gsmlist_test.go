@@ -0,0 +1,139 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestReflectorGSMFilter(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/db_password/versions/latest": []byte("hunter2"),
+		"projects/foo/secrets/api-key/versions/latest":     []byte("abc"),
+		"projects/foo/secrets/unsynced/versions/latest":    []byte("nope"),
+	})
+	gsmClient.Labels = map[string]map[string]string{
+		"projects/foo/secrets/db_password": {"k8s-sync": "true", "team": "data"},
+		"projects/foo/secrets/api-key":     {"k8s-sync": "true", "team": "web"},
+		"projects/foo/secrets/unsynced":    {"k8s-sync": "false", "team": "web"},
+	}
+
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+
+	mappings := []Mapping{
+		{
+			SourceType:         GSMSourceType,
+			Path:               "projects/foo",
+			GSMFilter:          "labels.k8s-sync=true",
+			SecretNameTemplate: "{{ .Labels.team }}-{{ .Name }}",
+			GSMSecretKeyValue:  "value",
+		},
+	}
+	if err := r.Reflect(ctx, mappings); err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	expected := map[string]string{
+		"data-db-password": "hunter2",
+		"web-api-key":      "abc",
+	}
+	checkSecrets := func() {
+		t.Helper()
+		list, err := secrets.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("error listing secrets: %s", err)
+		}
+		var names []string
+		for _, s := range list.Items {
+			names = append(names, s.Name)
+			if string(s.Data["value"]) != expected[s.Name] {
+				t.Fatalf("secret %s should contain %q, got %q", s.Name, expected[s.Name], s.Data["value"])
+			}
+			if s.Annotations[AnnotationGSMFilterPath] != "projects/foo" {
+				t.Fatalf("secret %s should be annotated with its list path", s.Name)
+			}
+		}
+		expectedNames := slices.Sorted(maps.Keys(expected))
+		slices.Sort(names)
+		if !slices.Equal(names, expectedNames) {
+			t.Fatalf("expected secrets %v, got %v", expectedNames, names)
+		}
+	}
+	checkSecrets()
+
+	// secrets that stop matching the filter are deleted
+	gsmClient.Labels["projects/foo/secrets/api-key"]["k8s-sync"] = "false"
+	if err := r.Reflect(ctx, mappings); err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	delete(expected, "web-api-key")
+	checkSecrets()
+
+	// secrets aren't deleted when the project can't be listed
+	r = NewReflector(
+		vault.NewMock(nil),
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+		WithGSMSecretLister(failingLister{}),
+		WithContinueOnError(),
+	)
+	results, err := r.ReflectResults(ctx, mappings)
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected a listing error, got %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionFailed {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	checkSecrets()
+}
+
+func TestReflectorGSMFilterCollision(t *testing.T) {
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/foo_bar/versions/latest": []byte("a"),
+		"projects/foo/secrets/foo-bar/versions/latest": []byte("b"),
+	})
+	gsmClient.Labels = map[string]map[string]string{
+		"projects/foo/secrets/foo_bar": {"k8s-sync": "true"},
+		"projects/foo/secrets/foo-bar": {"k8s-sync": "true"},
+	}
+
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		k8sfake.NewClientset(), DefaultNamespace,
+		DefaultLabelValue,
+	)
+	err := r.Reflect(context.Background(), []Mapping{
+		{SourceType: GSMSourceType, Path: "projects/foo", GSMFilter: "labels.k8s-sync=true"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "would both be written to kubernetes secret foo-bar") {
+		t.Fatalf("expected a name collision error, got %v", err)
+	}
+}
+
+// failingLister is a gsm.SecretLister that can't list anything.
+type failingLister struct{}
+
+func (failingLister) ListSecrets(context.Context, string, string) ([]*secretmanagerpb.Secret, error) {
+	return nil, fmt.Errorf("permission denied")
+}
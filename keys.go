@@ -0,0 +1,99 @@
+package pentagon
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// KeyRules select and rename the keys of a mapping's secret.  They're
+// applied in order: Required keys are checked, then keys are filtered by
+// Include and Exclude, renamed by Rename, and finally prefixed with Prefix.
+type KeyRules struct {
+	// Include, if set, only keeps the listed source keys.
+	Include []string `yaml:"include"`
+
+	// Exclude drops the listed source keys.
+	Exclude []string `yaml:"exclude"`
+
+	// Rename maps source keys to the keys they're written to.
+	Rename map[string]string `yaml:"rename"`
+
+	// Prefix is prepended to every key that's written.
+	Prefix string `yaml:"prefix"`
+
+	// Required are source keys that must be present, otherwise the mapping
+	// fails.
+	Required []string `yaml:"required"`
+}
+
+// empty returns true if k doesn't change any keys.
+func (k KeyRules) empty() bool {
+	return len(k.Include) == 0 &&
+		len(k.Exclude) == 0 &&
+		len(k.Rename) == 0 &&
+		k.Prefix == "" &&
+		len(k.Required) == 0
+}
+
+// validate checks that the keys produced by k are valid kubernetes secret
+// keys.
+func (k KeyRules) validate() error {
+	for _, from := range slices.Sorted(maps.Keys(k.Rename)) {
+		to := k.Rename[from]
+		if errs := validation.IsConfigMapKey(k.Prefix + to); len(errs) > 0 {
+			return fmt.Errorf("invalid key %q for %q: %s", k.Prefix+to, from, strings.Join(errs, ", "))
+		}
+	}
+	if k.Prefix != "" {
+		if errs := validation.IsConfigMapKey(k.Prefix); len(errs) > 0 {
+			return fmt.Errorf("invalid key prefix %q: %s", k.Prefix, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// apply returns the keys of data selected and renamed by k.  It fails if a
+// required key is missing, or if renaming would write two keys to one.
+func (k KeyRules) apply(data map[string][]byte) (map[string][]byte, error) {
+	if k.empty() {
+		return data, nil
+	}
+
+	var missing []string
+	for _, key := range k.Required {
+		if _, ok := data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required keys: %s", strings.Join(missing, ", "))
+	}
+
+	applied := make(map[string][]byte, len(data))
+	sources := make(map[string]string, len(data))
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		if len(k.Include) > 0 && !slices.Contains(k.Include, key) {
+			continue
+		}
+		if slices.Contains(k.Exclude, key) {
+			continue
+		}
+
+		to := key
+		if renamed, ok := k.Rename[key]; ok {
+			to = renamed
+		}
+		to = k.Prefix + to
+
+		if other, ok := sources[to]; ok {
+			return nil, fmt.Errorf("keys %q and %q would both be written to key %q", other, key, to)
+		}
+		sources[to] = key
+		applied[to] = data[key]
+	}
+	return applied, nil
+}
@@ -0,0 +1,164 @@
+package pentagon
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestKeyRules(t *testing.T) {
+	data := map[string][]byte{
+		"db_user":  []byte("app"),
+		"db_pass":  []byte("hunter2"),
+		"db_host":  []byte("db.internal"),
+		"internal": []byte("nope"),
+	}
+
+	for name, test := range map[string]struct {
+		rules KeyRules
+		keys  []string
+	}{
+		"empty": {
+			rules: KeyRules{},
+			keys:  []string{"db_host", "db_pass", "db_user", "internal"},
+		},
+		"include": {
+			rules: KeyRules{Include: []string{"db_user", "db_pass", "db_missing"}},
+			keys:  []string{"db_pass", "db_user"},
+		},
+		"exclude": {
+			rules: KeyRules{Exclude: []string{"internal", "db_host"}},
+			keys:  []string{"db_pass", "db_user"},
+		},
+		"rename-prefix": {
+			rules: KeyRules{
+				Include: []string{"db_user", "db_pass"},
+				Rename:  map[string]string{"db_pass": "PASSWORD", "db_user": "USER"},
+				Prefix:  "DB_",
+			},
+			keys: []string{"DB_PASSWORD", "DB_USER"},
+		},
+		"required": {
+			rules: KeyRules{Required: []string{"db_pass"}, Include: []string{"db_pass"}},
+			keys:  []string{"db_pass"},
+		},
+	} {
+		applied, err := test.rules.apply(data)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if keys := slices.Sorted(maps.Keys(applied)); !slices.Equal(keys, test.keys) {
+			t.Errorf("%s: expected keys %v, got %v", name, test.keys, keys)
+		}
+	}
+
+	// renamed keys keep their values
+	applied, err := KeyRules{Rename: map[string]string{"db_pass": "password"}}.apply(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(applied["password"]) != "hunter2" {
+		t.Fatalf("renamed key should have the value of db_pass, has %q", applied["password"])
+	}
+
+	_, err = KeyRules{Required: []string{"db_pass", "db_port", "db_name"}}.apply(data)
+	if err == nil || !strings.Contains(err.Error(), "db_port, db_name") {
+		t.Fatalf("expected a missing required keys error, got %v", err)
+	}
+
+	_, err = KeyRules{Rename: map[string]string{"db_user": "db_pass"}}.apply(data)
+	if err == nil || strings.Contains(err.Error(), "hunter2") || strings.Contains(err.Error(), "app") {
+		t.Fatalf("expected a rename collision error without values, got %v", err)
+	}
+}
+
+func TestReflectorKeyRules(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/db", map[string]any{
+		"db_user": "app",
+		"db_pass": "hunter2",
+		"admin":   "root",
+	})
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/config/versions/latest": []byte(`{"api_key": "abc", "debug": "true"}`),
+	})
+
+	r := NewReflector(
+		vaultClient,
+		gsmClient,
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/db",
+			SecretName:      "db",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			Keys: KeyRules{
+				Exclude:  []string{"admin"},
+				Rename:   map[string]string{"db_pass": "password", "db_user": "username"},
+				Required: []string{"db_pass"},
+			},
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/config/versions/latest",
+			SecretName:      "config",
+			GSMEncodingType: GSMEncodingTypeJSON,
+			Keys: KeyRules{
+				Include: []string{"api_key"},
+				Prefix:  "APP_",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	db, err := secrets.Get(ctx, "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret db should be there: %s", err)
+	}
+	if len(db.Data) != 2 || string(db.Data["username"]) != "app" || string(db.Data["password"]) != "hunter2" {
+		t.Fatalf("db should only have the renamed keys, has %v", slices.Sorted(maps.Keys(db.Data)))
+	}
+
+	config, err := secrets.Get(ctx, "config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret config should be there: %s", err)
+	}
+	if len(config.Data) != 1 || string(config.Data["APP_api_key"]) != "abc" {
+		t.Fatalf("config should only have the prefixed api key, has %v", slices.Sorted(maps.Keys(config.Data)))
+	}
+
+	// a missing required key fails the mapping
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/db",
+			SecretName:      "db",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			Keys:            KeyRules{Required: []string{"db_port"}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing required keys: db_port") {
+		t.Fatalf("expected a missing required key error, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// isListMapping returns true if mapping is expanded into one mapping per
+// secret it lists.
+func isListMapping(mapping Mapping) bool {
+	return mapping.VaultList != "" || mapping.GSMFilter != ""
+}
+
+// listPathAnnotation returns the annotation that records the path of the
+// list mapping that a secret from sourceType was generated from.
+func listPathAnnotation(sourceType string) string {
+	if sourceType == GSMSourceType {
+		return AnnotationGSMFilterPath
+	}
+	return AnnotationVaultListPath
+}
+
+// listing is the result of listing a list mapping.
+type listing struct {
+	mappings []Mapping
+	err      error
+}
+
+// listKey identifies a list mapping, so that it's only listed once per run.
+func listKey(mapping Mapping) string {
+	return strings.Join([]string{
+		mapping.SourceType,
+		mapping.VaultNamespace,
+		mapping.Path,
+		mapping.VaultList,
+		mapping.GSMFilter,
+		mapping.SecretNameTemplate,
+	}, "\x00")
+}
+
+// expandListMapping returns the mappings generated by a list mapping, caching
+// the result in listings.
+func (r *Reflector) expandListMapping(
+	ctx context.Context,
+	mapping Mapping,
+	listings map[string]listing,
+) ([]Mapping, error) {
+	key := listKey(mapping)
+	if l, ok := listings[key]; ok {
+		return l.mappings, l.err
+	}
+
+	var mappings []Mapping
+	var err error
+	switch mapping.SourceType {
+	case VaultSourceType:
+		mappings, err = r.expandVaultList(mapping)
+	case GSMSourceType:
+		mappings, err = r.expandGSMFilter(ctx, mapping)
+	default:
+		err = fmt.Errorf("%s mappings can't list secrets", mapping.SourceType)
+	}
+	listings[key] = listing{mappings: mappings, err: err}
+	return mappings, err
+}
+
+// secretNamer names the kubernetes secrets generated by a list mapping, and
+// makes sure that no two of them get the same name.
+type secretNamer struct {
+	tmpl    *template.Template
+	sources map[string]string
+}
+
+// newSecretNamer returns a secretNamer for mapping.
+func newSecretNamer(mapping Mapping) (*secretNamer, error) {
+	tmpl, err := parseSecretNameTemplate(mapping.SecretNameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret name template: %s", err)
+	}
+	return &secretNamer{
+		tmpl:    tmpl,
+		sources: map[string]string{},
+	}, nil
+}
+
+// name returns the name of the kubernetes secret for the secret at source,
+// which is described by data.
+func (n *secretNamer) name(source string, data secretNameData) (string, error) {
+	name, err := executeSecretNameTemplate(n.tmpl, data)
+	if err != nil {
+		return "", fmt.Errorf("error naming secret for %s: %s", source, err)
+	}
+	if other, ok := n.sources[name]; ok {
+		return "", fmt.Errorf(
+			"%s and %s would both be written to kubernetes secret %s",
+			other,
+			source,
+			name,
+		)
+	}
+	n.sources[name] = source
+	return name, nil
+}
@@ -0,0 +1,111 @@
+// Package metrics exposes Prometheus metrics describing the outcome of
+// reflecting secrets into kubernetes.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Phase identifies the step of reflecting a secret in which an error occurred.
+type Phase string
+
+const (
+	// PhaseList is listing the existing pentagon-managed kubernetes secrets.
+	PhaseList Phase = "list"
+
+	// PhaseFetch is reading a secret from its source (vault, gsm, etc.).
+	PhaseFetch Phase = "fetch"
+
+	// PhaseCreate is creating a new kubernetes secret.
+	PhaseCreate Phase = "create"
+
+	// PhaseUpdate is updating an existing kubernetes secret.
+	PhaseUpdate Phase = "update"
+
+	// PhaseReconcileDelete is deleting a kubernetes secret that is no longer
+	// present in the mappings.
+	PhaseReconcileDelete Phase = "reconcile-delete"
+)
+
+const namespace = "pentagon"
+
+// Metrics records reflection outcomes.  A nil *Metrics is valid and records
+// nothing, so callers don't need to check whether metrics are enabled.
+type Metrics struct {
+	syncs        *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	fetchLatency *prometheus.HistogramVec
+	lastSuccess  *prometheus.GaugeVec
+}
+
+// New creates the pentagon metrics and registers them with reg.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		syncs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "syncs_total",
+			Help:      "Number of successful syncs of a source secret into a kubernetes secret.",
+		}, []string{"cluster", "namespace", "secret_name", "source_type"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Number of errors encountered while reflecting secrets, by phase.",
+		}, []string{"phase", "cluster", "namespace", "secret_name"}),
+		fetchLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "Latency of reading secrets from their source.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"source_type"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_successful_sync_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful sync of a kubernetes secret.",
+		}, []string{"cluster", "namespace", "secret_name"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.syncs,
+		m.errors,
+		m.fetchLatency,
+		m.lastSuccess,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveFetch records how long reading a secret from sourceType took.
+func (m *Metrics) ObserveFetch(sourceType string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.fetchLatency.WithLabelValues(sourceType).Observe(d.Seconds())
+}
+
+// Synced records a successful sync of secretName in namespace of cluster
+// from sourceType at time t.  cluster is empty unless pentagon writes to
+// several clusters.
+func (m *Metrics) Synced(cluster, namespace, secretName, sourceType string, t time.Time) {
+	if m == nil {
+		return
+	}
+	m.syncs.WithLabelValues(cluster, namespace, secretName, sourceType).Inc()
+	m.lastSuccess.WithLabelValues(cluster, namespace, secretName).Set(float64(t.Unix()))
+}
+
+// Error records an error during phase for secretName in namespace of
+// cluster.  Any of them may be empty for errors that aren't specific to a
+// single cluster, namespace or secret, like failing to read a source secret,
+// which is only read once for every cluster.
+func (m *Metrics) Error(phase Phase, cluster, namespace, secretName string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(string(phase), cluster, namespace, secretName).Inc()
+}
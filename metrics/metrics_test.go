@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg)
+	if err != nil {
+		t.Fatalf("unable to create metrics: %s", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	m.Synced("east", "default", "foo", "vault", now)
+	m.Synced("east", "default", "foo", "vault", now)
+	m.Synced("west", "default", "foo", "vault", now.Add(-time.Hour))
+	m.Error(PhaseFetch, "", "", "bar")
+	m.ObserveFetch("gsm", time.Second)
+
+	if v := testutil.ToFloat64(m.syncs.WithLabelValues("east", "default", "foo", "vault")); v != 2 {
+		t.Errorf("expected 2 syncs, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.lastSuccess.WithLabelValues("east", "default", "foo")); v != float64(now.Unix()) {
+		t.Errorf("unexpected last success timestamp: %v", v)
+	}
+	// each cluster keeps its own timestamp
+	if v := testutil.ToFloat64(m.lastSuccess.WithLabelValues("west", "default", "foo")); v != float64(now.Add(-time.Hour).Unix()) {
+		t.Errorf("unexpected last success timestamp in west: %v", v)
+	}
+	if v := testutil.ToFloat64(m.errors.WithLabelValues(string(PhaseFetch), "", "", "bar")); v != 1 {
+		t.Errorf("expected 1 fetch error, got %v", v)
+	}
+	if c := testutil.CollectAndCount(m.fetchLatency); c != 1 {
+		t.Errorf("expected 1 fetch latency series, got %d", c)
+	}
+
+	if _, err := New(reg); err == nil {
+		t.Error("registering metrics twice should fail")
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	// none of these should panic
+	m.Synced("", "default", "foo", "vault", time.Now())
+	m.Error(PhaseCreate, "", "default", "foo")
+	m.ObserveFetch("vault", time.Second)
+}
@@ -0,0 +1,115 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Namespaces selects the kubernetes namespaces that a mapping's secret is
+// written to: those listed in Names, plus those whose labels match Selector.
+// In YAML, it's either an object with names and selector, or just a list of
+// names.
+type Namespaces struct {
+	// Names are the names of the namespaces.
+	Names []string `yaml:"names"`
+
+	// Selector is a label selector, like "team=core,env!=dev", matching
+	// more namespaces.  The matching namespaces are listed on every run.
+	Selector string `yaml:"selector"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting a list of names as
+// well as an object.
+func (n *Namespaces) UnmarshalYAML(unmarshal func(any) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		*n = Namespaces{Names: names}
+		return nil
+	}
+
+	type plain Namespaces
+	return unmarshal((*plain)(n))
+}
+
+// empty returns true if n doesn't select any namespaces, in which case the
+// mapping's secret is written to the default namespace.
+func (n Namespaces) empty() bool {
+	return len(n.Names) == 0 && n.Selector == ""
+}
+
+// validate checks that n's names and selector are valid.
+func (n Namespaces) validate() error {
+	for _, name := range n.Names {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	if n.Selector != "" {
+		if _, err := labels.Parse(n.Selector); err != nil {
+			return fmt.Errorf("invalid namespace selector %q: %s", n.Selector, err)
+		}
+	}
+	return nil
+}
+
+// managedNamespaces returns the namespaces of c that the secrets of mappings
+// are written to, along with the cluster's own namespace, in which pentagon
+// has always managed secrets.
+func (r *Reflector) managedNamespaces(ctx context.Context, c *cluster, mappings []Mapping) ([]string, error) {
+	managed := map[string]struct{}{c.Namespace: {}}
+	for _, mapping := range mappings {
+		if !c.targets(mapping) {
+			continue
+		}
+		namespaces, err := r.mappingNamespaces(ctx, c, mapping)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range namespaces {
+			managed[namespace] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(managed)), nil
+}
+
+// mappingNamespaces returns the namespaces of c that mapping's secret is
+// written to, in order.  Namespaces matching a selector are listed once per
+// run, and those that are being deleted are left out since secrets can't be
+// written to them.
+func (r *Reflector) mappingNamespaces(ctx context.Context, c *cluster, mapping Mapping) ([]string, error) {
+	if mapping.Namespaces.empty() {
+		return []string{c.Namespace}, nil
+	}
+
+	namespaces := slices.Clone(mapping.Namespaces.Names)
+	if selector := mapping.Namespaces.Selector; selector != "" {
+		selected, ok := c.selectedNamespaces[selector]
+		if !ok {
+			list, err := c.Client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+				LabelSelector: selector,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error listing namespaces matching %q: %s", selector, err)
+			}
+			for _, namespace := range list.Items {
+				if namespace.Status.Phase == corev1.NamespaceTerminating {
+					continue
+				}
+				selected = append(selected, namespace.Name)
+			}
+			c.selectedNamespaces[selector] = selected
+		}
+		namespaces = append(namespaces, selected...)
+	}
+
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
+}
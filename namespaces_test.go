@@ -0,0 +1,197 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+// countingAccessor counts the GSM secret versions that are accessed.
+type countingAccessor struct {
+	*gsm.MockGSM
+	accessed int
+}
+
+func (c *countingAccessor) AccessSecretVersion(
+	ctx context.Context,
+	req *secretmanagerpb.AccessSecretVersionRequest,
+	opts ...gax.CallOption,
+) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	c.accessed++
+	return c.MockGSM.AccessSecretVersion(ctx, req, opts...)
+}
+
+func TestReflectorNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	// team-a and team-b match the selector, but team-b is being deleted
+	k8sClient := k8sfake.NewClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"pentagon": "enabled"},
+		}},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "team-b",
+				Labels: map[string]string{"pentagon": "enabled"},
+			},
+			Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}},
+	)
+
+	// stale secrets in a managed namespace are reconciled, while those in
+	// namespaces pentagon doesn't manage, which may belong to another
+	// pentagon with the same label, are left alone
+	for _, namespace := range []string{"team-a", "other"} {
+		_, err := k8sClient.CoreV1().Secrets(namespace).Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "stale",
+				Labels: map[string]string{LabelKey: "test"},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("unable to create secret: %s", err)
+		}
+	}
+
+	gsmClient := &countingAccessor{MockGSM: gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/db/versions/latest": []byte("hunter2"),
+	})}
+
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsmClient,
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+
+	mapping := Mapping{
+		SourceType:      GSMSourceType,
+		Path:            "projects/foo/secrets/db/versions/latest",
+		SecretName:      "db",
+		GSMEncodingType: GSMEncodingTypeDefault,
+		Namespaces: Namespaces{
+			Names:    []string{"team-c"},
+			Selector: "pentagon=enabled",
+		},
+	}
+	results, err := r.ReflectResults(ctx, []Mapping{mapping})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	if gsmClient.accessed != 1 {
+		t.Fatalf("secret should have been fetched once, not %d times", gsmClient.accessed)
+	}
+
+	var actions []string
+	for _, result := range results {
+		actions = append(actions, fmt.Sprintf("%s/%s %s", result.Namespace, result.SecretName, result.Action))
+	}
+	expected := "team-a/db created, team-c/db created, team-a/stale deleted"
+	if strings.Join(actions, ", ") != expected {
+		t.Fatalf("expected %s, got %s", expected, strings.Join(actions, ", "))
+	}
+
+	for _, namespace := range []string{"team-a", "team-c"} {
+		secret, err := k8sClient.CoreV1().Secrets(namespace).Get(ctx, "db", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret should be in %s: %s", namespace, err)
+		}
+		if string(secret.Data["db"]) != "hunter2" {
+			t.Fatalf("unexpected data in %s: %v", namespace, secret.Data)
+		}
+	}
+	_, err = k8sClient.CoreV1().Secrets("team-b").Get(ctx, "db", metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("secret shouldn't be in the namespace being deleted: %v", err)
+	}
+	_, err = k8sClient.CoreV1().Secrets("other").Get(ctx, "stale", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret in an unmanaged namespace should be left alone: %s", err)
+	}
+}
+
+func TestReflectorOverlappingNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	k8sClient := k8sfake.NewClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "team-a",
+		Labels: map[string]string{"pentagon": "enabled"},
+	}})
+
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsm.NewMockGSM(map[string][]byte{
+			"projects/foo/secrets/a/versions/latest": []byte("a"),
+			"projects/foo/secrets/b/versions/latest": []byte("b"),
+		}),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithContinueOnError(),
+	)
+
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/a/versions/latest",
+			SecretName:      "app",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Namespaces:      Namespaces{Names: []string{"team-a"}},
+		},
+		{
+			SourceType:      GSMSourceType,
+			Path:            "projects/foo/secrets/b/versions/latest",
+			SecretName:      "app",
+			GSMEncodingType: GSMEncodingTypeDefault,
+			Namespaces:      Namespaces{Selector: "pentagon=enabled"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "would both be written to kubernetes secret team-a/app") {
+		t.Fatalf("failed to detect mappings writing to the same secret: %v", err)
+	}
+	if len(results) != 2 || results[0].Action != ActionCreated || results[1].Action != ActionFailed {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestReflectorNamespaceSelectorError(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+	k8sClient.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("forbidden")
+	})
+
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+	)
+
+	_, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType: GSMSourceType,
+			Path:       "projects/foo/secrets/a/versions/latest",
+			SecretName: "app",
+			Namespaces: Namespaces{Selector: "pentagon=enabled"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "error listing namespaces") {
+		t.Fatalf("expected an error listing namespaces, got %v", err)
+	}
+}
@@ -2,9 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/url"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -12,12 +13,21 @@ import (
 
 	"cloud.google.com/go/compute/metadata"
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	yaml "gopkg.in/yaml.v2"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/vimeo/pentagon"
+	"github.com/vimeo/pentagon/azurekv"
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/metrics"
 	"github.com/vimeo/pentagon/vault"
 )
 
@@ -35,16 +45,53 @@ func main() {
 		signal.Stop(sigChan)
 	}()
 
-	if len(os.Args) != 2 {
+	daemon := flag.Bool(
+		"daemon",
+		false,
+		"keep running and re-reflect each mapping on its refresh interval",
+	)
+	metricsAddr := flag.String(
+		"metrics-addr",
+		"",
+		"address on which to serve prometheus metrics at /metrics (disabled if empty)",
+	)
+	dryRun := flag.Bool(
+		"dry-run",
+		false,
+		"print the changes that would be made to kubernetes without applying them",
+	)
+	serverDryRun := flag.Bool(
+		"dry-run-server",
+		false,
+		"like -dry-run, but also submit the changes to kubernetes as server-side dry-run requests",
+	)
+	output := flag.String(
+		"output",
+		"text",
+		`format of the dry-run plan: "text" or "json"`,
+	)
+	flag.Parse()
+
+	if flag.NArg() != 1 {
 		log.Printf(
-			"incorrect number of arguments. need 2, got %d [%#v]",
-			len(os.Args),
-			os.Args,
+			"incorrect number of arguments. need 1, got %d [%#v]",
+			flag.NArg(),
+			flag.Args(),
 		)
 		os.Exit(10)
 	}
 
-	configFile, err := os.ReadFile(os.Args[1])
+	*dryRun = *dryRun || *serverDryRun
+	if *dryRun && *daemon {
+		log.Printf("--dry-run cannot be combined with --daemon")
+		os.Exit(10)
+	}
+	if *output != "text" && *output != "json" {
+		log.Printf("unknown output format: %q", *output)
+		os.Exit(10)
+	}
+
+	configFile, err := os.ReadFile(flag.Arg(0))
 	if err != nil {
 		log.Printf("error opening configuration file: %s", err)
 		os.Exit(20)
@@ -64,45 +111,187 @@ func main() {
 		os.Exit(22)
 	}
 
-	vaultClient, err := getVaultClient(config.Vault)
+	vaultClient, tokenManager, err := getVaultClient(config.Vault)
 	if err != nil {
 		log.Printf("unable to get vault client: %s", err)
 		os.Exit(30)
 	}
+	stopTokenManager := startTokenManager(tokenManager)
+	defer stopTokenManager()
+
+	// exit revokes the vault token before exiting, since os.Exit doesn't
+	// run deferred functions.
+	exit := func(code int) {
+		stopTokenManager()
+		os.Exit(code)
+	}
 
-	k8sClient, err := getK8sClient()
+	clusters, err := getK8sClusters(config)
 	if err != nil {
 		log.Printf("unable to get kubernetes client: %s", err)
-		os.Exit(31)
+		exit(31)
 	}
 
 	gsmClient, err := secretmanager.NewClient(ctx)
 	if err != nil {
 		log.Printf("unable to get GSM client: %s", err)
-		os.Exit(32)
+		exit(32)
 	}
 	defer gsmClient.Close()
 
-	reflector := pentagon.NewReflector(
-		vaultClient.Logical(),
+	opts := []pentagon.Option{
+		pentagon.WithGSMSecretLister(gsm.NewClientLister(gsmClient)),
+	}
+	if usesSourceType(config.Mappings, pentagon.AzureSourceType) {
+		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			log.Printf("unable to get Azure Key Vault client: %s", err)
+			exit(35)
+		}
+		opts = append(opts, pentagon.WithAzureKeyVault(azurekv.NewClient(credential)))
+	}
+	if usesSourceType(config.Mappings, pentagon.AWSSourceType) {
+		awsConfig, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			log.Printf("unable to get AWS Secrets Manager client: %s", err)
+			exit(34)
+		}
+		opts = append(opts, pentagon.WithAWSSecretsManager(
+			secretsmanager.NewFromConfig(awsConfig),
+		))
+	}
+	if config.ContinueOnError {
+		opts = append(opts, pentagon.WithContinueOnError())
+	}
+	if config.ForceConflicts {
+		opts = append(opts, pentagon.WithForceConflicts())
+	}
+	if *dryRun {
+		opts = append(opts, pentagon.WithDryRun(*serverDryRun))
+	}
+	if *metricsAddr != "" {
+		m, err := metrics.New(prometheus.DefaultRegisterer)
+		if err != nil {
+			log.Printf("unable to register metrics: %s", err)
+			exit(33)
+		}
+		opts = append(opts, pentagon.WithMetrics(m))
+		go serveMetrics(ctx, *metricsAddr)
+	}
+
+	reflector := pentagon.NewClusterReflector(
+		vault.NewClient(vaultClient),
 		gsmClient,
-		k8sClient,
-		config.Namespace,
+		clusters,
 		config.Label,
+		opts...,
 	)
 
+	if *daemon {
+		reflector.Run(ctx, config.Mappings, config.RefreshInterval)
+		return
+	}
+
+	if *dryRun {
+		results, err := reflector.ReflectResults(ctx, config.Mappings)
+		writePlan := pentagon.WritePlan
+		if *output == "json" {
+			writePlan = pentagon.WritePlanJSON
+		}
+		if err := writePlan(os.Stdout, results); err != nil {
+			log.Printf("error writing plan: %s", err)
+		}
+		if err != nil {
+			log.Printf("error planning secrets reflection: %s", err)
+			exit(40)
+		}
+		return
+	}
+
 	err = reflector.Reflect(ctx, config.Mappings)
 	if err != nil {
 		log.Printf("error reflecting secrets into kubernetes: %s", err)
-		os.Exit(40)
+		exit(40)
+	}
+}
+
+// usesSourceType returns true if any of mappings, or any of the sources
+// merged into them, is sourced from sourceType.
+func usesSourceType(mappings []pentagon.Mapping, sourceType string) bool {
+	for _, m := range mappings {
+		if m.SourceType == sourceType || usesSourceType(m.Sources, sourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveMetrics serves prometheus metrics on addr until ctx is cancelled.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("error serving metrics: %s", err)
+	}
+}
+
+// getK8sClusters returns the clusters in config, or the cluster pentagon runs
+// in if there aren't any.
+func getK8sClusters(config *pentagon.Config) ([]pentagon.Cluster, error) {
+	if len(config.Clusters) == 0 {
+		clientset, err := getK8sClient(pentagon.ClusterConfig{InCluster: true})
+		if err != nil {
+			return nil, err
+		}
+		return []pentagon.Cluster{{Client: clientset, Namespace: config.Namespace}}, nil
 	}
+
+	clusters := make([]pentagon.Cluster, 0, len(config.Clusters))
+	for _, c := range config.Clusters {
+		clientset, err := getK8sClient(c)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %s", c.Name, err)
+		}
+		clusters = append(clusters, pentagon.Cluster{
+			Name:      c.Name,
+			Client:    clientset,
+			Namespace: c.Namespace,
+		})
+	}
+	return clusters, nil
 }
 
-func getK8sClient() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
+// getK8sClient returns a client for the cluster described by c.  This
+// doesn't connect to the cluster, so clusters that can't be reached are only
+// noticed when reflecting.
+func getK8sClient(c pentagon.ClusterConfig) (*kubernetes.Clientset, error) {
+	var config *rest.Config
+	var err error
+	if c.InCluster {
+		config, err = rest.InClusterConfig()
+	} else {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = c.Kubeconfig
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			rules,
+			&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+		).ClientConfig()
+	}
 	if err != nil {
 		return nil, err
 	}
+	config.Timeout = c.Timeout
+
 	// creates the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -112,7 +301,7 @@ func getK8sClient() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-func getVaultClient(vaultConfig pentagon.VaultConfig) (*api.Client, error) {
+func getVaultClient(vaultConfig pentagon.VaultConfig) (*api.Client, *vault.TokenManager, error) {
 	c := api.DefaultConfig()
 	c.Address = vaultConfig.URL
 
@@ -125,12 +314,14 @@ func getVaultClient(vaultConfig pentagon.VaultConfig) (*api.Client, error) {
 
 	client, err := api.NewClient(c)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	var auth vault.Authenticator
 	switch vaultConfig.AuthType {
 	case vault.AuthTypeToken:
 		client.SetToken(vaultConfig.Token)
+		return client, nil, nil
 	case vault.AuthTypeGCPDefault:
 		// default to using configured Role
 		role := vaultConfig.Role
@@ -139,72 +330,75 @@ func getVaultClient(vaultConfig pentagon.VaultConfig) (*api.Client, error) {
 		if role == "" {
 			role, err = getRoleViaGCP()
 			if err != nil {
-				return nil, fmt.Errorf("error getting role from gcp: %s", err)
+				return nil, nil, fmt.Errorf("error getting role from gcp: %s", err)
 			}
 		}
 
-		err := setVaultTokenViaGCP(client, role)
-		if err != nil {
-			return nil, fmt.Errorf("unable to set token via gcp: %s", err)
+		auth = vault.GCPAuth{
+			MountPath:    vaultConfig.AuthMountPath,
+			Role:         role,
+			VaultAddress: client.Address(),
+		}
+	case vault.AuthTypeKubernetes:
+		auth = vault.KubernetesAuth{
+			MountPath: vaultConfig.AuthMountPath,
+			Role:      vaultConfig.Role,
+			TokenPath: vaultConfig.KubernetesTokenPath,
+		}
+	case vault.AuthTypeAppRole:
+		auth = vault.AppRoleAuth{
+			MountPath: vaultConfig.AuthMountPath,
+			RoleID:    vaultConfig.AppRole.RoleID,
+			SecretID:  vaultConfig.AppRole.SecretID,
 		}
 	default:
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"unsupported vault auth type: %s",
 			vaultConfig.AuthType,
 		)
 	}
 
-	return client, nil
-}
-
-func getRoleViaGCP() (string, error) {
-	emailAddress, err := metadata.Get("instance/service-accounts/default/email")
-	if err != nil {
-		return "", fmt.Errorf("error getting default email address: %s", err)
+	tokenManager := vault.NewTokenManager(client, auth)
+	tokenManager.Namespace = vaultConfig.AuthNamespace
+	if err := tokenManager.Login(); err != nil {
+		return nil, nil, fmt.Errorf(
+			"unable to set token via %s: %s",
+			vaultConfig.AuthType,
+			err,
+		)
 	}
-	components := strings.Split(emailAddress, "@")
-	return components[0], nil
+
+	return client, tokenManager, nil
 }
 
-func setVaultTokenViaGCP(vaultClient *api.Client, role string) error {
-	// just make a request directly to the metadata server rather
-	// than going through the APIs which don't seem to wrap this functionality
-	// in a terribly convenient way.
-	metadataURL := url.URL{
-		Path: "instance/service-accounts/default/identity",
+// startTokenManager keeps the vault token valid in the background, and
+// returns a function that stops doing so and revokes the token.  It's a no-op
+// if tokenManager is nil.
+func startTokenManager(tokenManager *vault.TokenManager) func() {
+	if tokenManager == nil {
+		return func() {}
 	}
 
-	values := url.Values{}
-	vaultAddress, err := url.Parse(vaultClient.Address())
-	if err != nil {
-		return fmt.Errorf("error parsing vault address: %s", err)
-	}
-	values.Add(
-		"audience",
-		fmt.Sprintf("%s/vault/%s", vaultAddress.Hostname(), role),
-	)
-	values.Add("format", "full")
-	metadataURL.RawQuery = values.Encode()
+	// This context is deliberately not derived from the signal-handling
+	// one so that the token isn't revoked until we're done using it.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tokenManager.Run(ctx)
+	}()
 
-	// `jwt` should be a base64-encoded jwt.
-	jwt, err := metadata.Get(metadataURL.String())
-	if err != nil {
-		return fmt.Errorf("error retrieving JWT from metadata API: %s", err)
+	return func() {
+		cancel()
+		<-done
 	}
+}
 
-	vaultResp, err := vaultClient.Logical().Write(
-		"auth/gcp/login",
-		map[string]any{
-			"role": role,
-			"jwt":  jwt,
-		},
-	)
-
+func getRoleViaGCP() (string, error) {
+	emailAddress, err := metadata.Get("instance/service-accounts/default/email")
 	if err != nil {
-		return fmt.Errorf("error authenticating to vault via gcp: %s", err)
+		return "", fmt.Errorf("error getting default email address: %s", err)
 	}
-
-	vaultClient.SetToken(vaultResp.Auth.ClientToken)
-
-	return nil
+	components := strings.Split(emailAddress, "@")
+	return components[0], nil
 }
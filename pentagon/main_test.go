@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vimeo/pentagon"
+)
+
+func TestUsesSourceType(t *testing.T) {
+	mappings := []pentagon.Mapping{
+		{SourceType: pentagon.VaultSourceType, Path: "secret/data/db", SecretName: "db"},
+		{
+			SecretName: "app",
+			Sources: []pentagon.Mapping{
+				{SourceType: pentagon.GSMSourceType, Path: "projects/foo/secrets/api/versions/latest"},
+				{SourceType: pentagon.AWSSourceType, Path: "app/api"},
+			},
+		},
+	}
+
+	for sourceType, expected := range map[string]bool{
+		pentagon.VaultSourceType: true,
+		pentagon.GSMSourceType:   true,
+		pentagon.AWSSourceType:   true,
+		pentagon.AzureSourceType: false,
+	} {
+		if used := usesSourceType(mappings, sourceType); used != expected {
+			t.Errorf("%s: expected %t, got %t", sourceType, expected, used)
+		}
+	}
+}
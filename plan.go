@@ -0,0 +1,161 @@
+package pentagon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Diff describes how an existing kubernetes secret differs from the secret
+// that pentagon wants to write.  It only ever contains key names, never
+// values.
+type Diff struct {
+	// Added are the data keys that will be added to the secret.
+	Added []string `json:"added,omitempty"`
+
+	// Removed are the data keys that will be removed from the secret.
+	Removed []string `json:"removed,omitempty"`
+
+	// Changed are the data keys whose values will change.
+	Changed []string `json:"changed,omitempty"`
+
+	// Metadata are the non-data fields ("type", "labels" or "annotations")
+	// that will change.
+	Metadata []string `json:"metadata,omitempty"`
+}
+
+// Empty returns true if there are no differences.
+func (d *Diff) Empty() bool {
+	return d == nil ||
+		len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Metadata) == 0
+}
+
+// diffSecrets compares the existing secret with the desired one.
+func diffSecrets(existing, desired *corev1.Secret) *Diff {
+	d := &Diff{}
+	for _, k := range slices.Sorted(maps.Keys(desired.Data)) {
+		existingVal, ok := existing.Data[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, k)
+		case !bytes.Equal(existingVal, desired.Data[k]):
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(existing.Data)) {
+		if _, ok := desired.Data[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+
+	if existing.Type != desired.Type {
+		d.Metadata = append(d.Metadata, "type")
+	}
+	if !maps.Equal(existing.Labels, desired.Labels) {
+		d.Metadata = append(d.Metadata, "labels")
+	}
+	if !maps.Equal(existing.Annotations, desired.Annotations) {
+		d.Metadata = append(d.Metadata, "annotations")
+	}
+
+	return d
+}
+
+// planVerbs are the imperative forms of each action, used when printing
+// plans.
+var planVerbs = map[Action]string{
+	ActionCreated:   "create",
+	ActionUpdated:   "update",
+	ActionUnchanged: "unchanged",
+	ActionDeleted:   "delete",
+	ActionAdopted:   "adopt",
+	ActionSkipped:   "skip",
+	ActionFailed:    "error",
+}
+
+// planSymbols prefix each line of a plan to make it easier to scan.
+var planSymbols = map[Action]string{
+	ActionCreated:   "+",
+	ActionUpdated:   "~",
+	ActionUnchanged: "=",
+	ActionDeleted:   "-",
+	ActionAdopted:   "*",
+	ActionSkipped:   "?",
+	ActionFailed:    "!",
+}
+
+// WritePlan writes a human-readable description of results to w, one line
+// per kubernetes secret.
+func WritePlan(w io.Writer, results []MappingResult) error {
+	for _, result := range results {
+		line := fmt.Sprintf(
+			"%s %s",
+			planSymbols[result.Action],
+			planVerbs[result.Action],
+		)
+		// vault list mappings that couldn't be listed don't have a secret
+		if result.SecretName != "" && result.Namespace != "" {
+			line += fmt.Sprintf(" %s/%s", result.Namespace, result.SecretName)
+		} else if result.SecretName != "" {
+			line += " " + result.SecretName
+		}
+		// so do results for clusters that couldn't be reached
+		if result.Cluster != "" {
+			line += " in cluster " + result.Cluster
+		}
+		if result.SourcePath != "" {
+			line += fmt.Sprintf(" (%s %s)", result.SourceType, result.SourcePath)
+		}
+		if result.Action == ActionUpdated && result.Diff != nil {
+			line += describeDiff(result.Diff)
+		}
+		if result.Warning != "" {
+			line += fmt.Sprintf(": %s", result.Warning)
+		}
+		if result.Err != nil {
+			line += fmt.Sprintf(": %s", result.Err)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeDiff summarizes d for WritePlan.
+func describeDiff(d *Diff) string {
+	var parts []string
+	for _, field := range []struct {
+		name string
+		keys []string
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"changed", d.Changed},
+		{"metadata", d.Metadata},
+	} {
+		if len(field.keys) > 0 {
+			parts = append(parts, fmt.Sprintf("%s [%s]", field.name, strings.Join(field.keys, ", ")))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(parts, "; ")
+}
+
+// WritePlanJSON writes results to w as a JSON array.
+func WritePlanJSON(w io.Writer, results []MappingResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if results == nil {
+		results = []MappingResult{}
+	}
+	return enc.Encode(results)
+}
@@ -0,0 +1,165 @@
+package pentagon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffSecrets(t *testing.T) {
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{LabelKey: "test"},
+		},
+		Data: map[string][]byte{
+			"same":    []byte("value"),
+			"changed": []byte("old"),
+			"removed": []byte("value"),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+
+	desired := existing.DeepCopy()
+	if d := diffSecrets(existing, desired); !d.Empty() {
+		t.Fatalf("identical secrets should have an empty diff: %+v", d)
+	}
+
+	desired.Data = map[string][]byte{
+		"same":    []byte("value"),
+		"changed": []byte("new"),
+		"added":   []byte("value"),
+	}
+	desired.Labels = map[string]string{LabelKey: "test", "team": "core"}
+
+	d := diffSecrets(existing, desired)
+	if !slices.Equal(d.Added, []string{"added"}) {
+		t.Errorf("unexpected added keys: %v", d.Added)
+	}
+	if !slices.Equal(d.Removed, []string{"removed"}) {
+		t.Errorf("unexpected removed keys: %v", d.Removed)
+	}
+	if !slices.Equal(d.Changed, []string{"changed"}) {
+		t.Errorf("unexpected changed keys: %v", d.Changed)
+	}
+	if !slices.Equal(d.Metadata, []string{"labels"}) {
+		t.Errorf("unexpected metadata changes: %v", d.Metadata)
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	results := []MappingResult{
+		{
+			SourceType: VaultSourceType,
+			SourcePath: "secrets/foo",
+			SecretName: "foo",
+			Action:     ActionCreated,
+			DryRun:     true,
+		},
+		{
+			SourceType: GSMSourceType,
+			SourcePath: "projects/foo/secrets/bar/versions/latest",
+			SecretName: "bar",
+			Action:     ActionUpdated,
+			DryRun:     true,
+			Diff: &Diff{
+				Added:   []string{"a"},
+				Changed: []string{"c"},
+			},
+		},
+		{
+			SourceType: VaultSourceType,
+			SourcePath: "secrets/missing",
+			SecretName: "missing",
+			Action:     ActionFailed,
+			DryRun:     true,
+			Err:        fmt.Errorf("secret secrets/missing not found"),
+		},
+		{
+			SecretName: "stale",
+			Action:     ActionDeleted,
+			DryRun:     true,
+		},
+		{
+			SourceType: VaultSourceType,
+			SourcePath: "secrets/legacy",
+			SecretName: "legacy",
+			Action:     ActionSkipped,
+			DryRun:     true,
+			Warning:    "kubernetes secret legacy already exists and isn't managed by pentagon, so it was skipped",
+		},
+		{
+			SourceType: VaultSourceType,
+			SourcePath: "secrets/shared",
+			Namespace:  "team-a",
+			SecretName: "shared",
+			Action:     ActionCreated,
+			DryRun:     true,
+		},
+		{
+			SourceType: VaultSourceType,
+			SourcePath: "secrets/shared",
+			Cluster:    "west",
+			Namespace:  "team-a",
+			SecretName: "shared",
+			Action:     ActionCreated,
+			DryRun:     true,
+		},
+		{
+			Cluster: "east",
+			Action:  ActionFailed,
+			DryRun:  true,
+			Err:     fmt.Errorf("error listing secrets in namespace default: connection refused"),
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WritePlan(buf, results); err != nil {
+		t.Fatalf("error writing plan: %s", err)
+	}
+
+	expected := `+ create foo (vault secrets/foo)
+~ update bar (gsm projects/foo/secrets/bar/versions/latest): added [a]; changed [c]
+! error missing (vault secrets/missing): secret secrets/missing not found
+- delete stale
+? skip legacy (vault secrets/legacy): kubernetes secret legacy already exists and isn't managed by pentagon, so it was skipped
++ create team-a/shared (vault secrets/shared)
++ create team-a/shared in cluster west (vault secrets/shared)
+! error in cluster east: error listing secrets in namespace default: connection refused
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected plan:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := WritePlanJSON(buf, results); err != nil {
+		t.Fatalf("error writing JSON plan: %s", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("plan was not valid JSON: %s", err)
+	}
+	if len(decoded) != len(results) {
+		t.Fatalf("expected %d entries, got %d", len(results), len(decoded))
+	}
+	if decoded[1]["action"] != string(ActionUpdated) {
+		t.Errorf("unexpected action: %v", decoded[1]["action"])
+	}
+	if decoded[2]["error"] != "secret secrets/missing not found" {
+		t.Errorf("unexpected error: %v", decoded[2]["error"])
+	}
+	if decoded[4]["warning"] != results[4].Warning {
+		t.Errorf("unexpected warning: %v", decoded[4]["warning"])
+	}
+	if decoded[5]["namespace"] != "team-a" {
+		t.Errorf("unexpected namespace: %v", decoded[5]["namespace"])
+	}
+	if decoded[6]["cluster"] != "west" {
+		t.Errorf("unexpected cluster: %v", decoded[6]["cluster"])
+	}
+}
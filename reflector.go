@@ -1,27 +1,125 @@
 package pentagon
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"log"
 	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/hashicorp/vault/api"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
+	"github.com/vimeo/pentagon/awssm"
+	"github.com/vimeo/pentagon/azurekv"
 	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/metrics"
 	"github.com/vimeo/pentagon/vault"
 )
 
 // LabelKey is the name of label that will be attached to every secret created by pentagon.
 const LabelKey = "pentagon"
 
+// FieldManager is the field manager that pentagon applies secrets as.
+const FieldManager = "pentagon"
+
+const (
+	// AnnotationPrefix is the prefix of the annotations that pentagon sets on
+	// the secrets it creates.
+	AnnotationPrefix = "pentagon.vimeo.com/"
+
+	// AnnotationVaultVersion is the version of the key/value v2 Vault secret
+	// that was reflected.
+	AnnotationVaultVersion = AnnotationPrefix + "vault-version"
+
+	// AnnotationVaultCreatedTime is when the version of the key/value v2 Vault
+	// secret that was reflected was created.
+	AnnotationVaultCreatedTime = AnnotationPrefix + "vault-created-time"
+
+	// AnnotationVaultCustomMetadata is the custom metadata of the key/value
+	// v2 Vault secret that was reflected, encoded as a JSON object.
+	AnnotationVaultCustomMetadata = AnnotationPrefix + "vault-custom-metadata"
+)
+
+// Option configures optional behavior of a Reflector.
+type Option func(*Reflector)
+
+// WithContinueOnError keeps reflecting the remaining mappings after one of
+// them fails instead of returning immediately.
+func WithContinueOnError() Option {
+	return func(r *Reflector) {
+		r.continueOnError = true
+	}
+}
+
+// WithDryRun computes what reflecting would change without writing anything
+// to kubernetes.  The returned results describe the planned changes.  If
+// serverSide is set, the writes are still sent to the kubernetes API as
+// server-side dry-run requests so that API validation errors are caught too.
+func WithDryRun(serverSide bool) Option {
+	return func(r *Reflector) {
+		r.dryRun = true
+		r.serverDryRun = serverSide
+	}
+}
+
+// WithForceConflicts takes ownership of any fields of the applied secrets
+// that another field manager has changed, rather than failing with a
+// conflict.
+func WithForceConflicts() Option {
+	return func(r *Reflector) {
+		r.forceConflicts = true
+	}
+}
+
+// WithAWSSecretsManager sets the client used to read secrets from AWS Secrets
+// Manager.
+func WithAWSSecretsManager(client awssm.SecretValueGetter) Option {
+	return func(r *Reflector) {
+		r.awsClient = client
+	}
+}
+
+// WithAzureKeyVault sets the client used to read secrets from Azure Key
+// Vault.
+func WithAzureKeyVault(client azurekv.SecretGetter) Option {
+	return func(r *Reflector) {
+		r.azureClient = client
+	}
+}
+
+// WithGSMSecretLister sets the client used to list the GSM secrets of
+// GSMFilter mappings.  It defaults to the GSM client passed to NewReflector,
+// if that can list secrets.
+func WithGSMSecretLister(lister gsm.SecretLister) Option {
+	return func(r *Reflector) {
+		r.gsmLister = lister
+	}
+}
+
+// WithMetrics records the outcome of each reflection in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(r *Reflector) {
+		r.metrics = m
+	}
+}
+
 // NewReflector returns a new reflector
 func NewReflector(
 	vaultClient vault.Logical,
@@ -29,128 +127,693 @@ func NewReflector(
 	k8sClient kubernetes.Interface,
 	k8sNamespace string,
 	labelValue string,
+	opts ...Option,
 ) *Reflector {
-	return &Reflector{
-		vaultClient:   vaultClient,
-		gsmClient:     gsmClient,
-		secretsClient: k8sClient.CoreV1().Secrets(k8sNamespace),
-		k8sNamespace:  k8sNamespace,
-		labelValue:    labelValue,
+	return NewClusterReflector(
+		vaultClient,
+		gsmClient,
+		[]Cluster{{Client: k8sClient, Namespace: k8sNamespace}},
+		labelValue,
+		opts...,
+	)
+}
+
+// NewClusterReflector returns a new reflector that writes secrets to each of
+// clusters.  Secrets are fetched once per run, whatever the number of
+// clusters they're written to.
+func NewClusterReflector(
+	vaultClient vault.Logical,
+	gsmClient gsm.SecretAccessor,
+	clusters []Cluster,
+	labelValue string,
+	opts ...Option,
+) *Reflector {
+	r := &Reflector{
+		vaultClient: vaultClient,
+		vaultMounts: vault.NewMountCache(),
+		gsmClient:   gsmClient,
+		labelValue:  labelValue,
+
+		retryInterval: DefaultRetryInterval,
 	}
+	for _, c := range clusters {
+		r.clusters = append(r.clusters, &cluster{Cluster: c})
+	}
+	if lister, ok := gsmClient.(gsm.SecretLister); ok {
+		r.gsmLister = lister
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Reflector moves secrets from Vault/GSM to Kubernetes
 type Reflector struct {
-	vaultClient   vault.Logical
-	gsmClient     gsm.SecretAccessor
-	secretsClient typedv1.SecretInterface
-	k8sNamespace  string
-	labelValue    string
-	secretsSet    map[string]struct{}
+	vaultClient vault.Logical
+	vaultMounts *vault.MountCache
+	gsmClient   gsm.SecretAccessor
+	gsmLister   gsm.SecretLister
+	awsClient   awssm.SecretValueGetter
+	azureClient azurekv.SecretGetter
+	clusters    []*cluster
+	labelValue  string
+	metrics     *metrics.Metrics
+
+	continueOnError bool
+	dryRun          bool
+	serverDryRun    bool
+	forceConflicts  bool
+
+	// retryInterval is how soon Run first retries a failed mapping.
+	retryInterval time.Duration
 }
 
 // Reflect syncs the values between Vault/GSM and k8s secrets based on the mappings passed.
 func (r *Reflector) Reflect(ctx context.Context, mappings []Mapping) error {
-	// create a set of existing k8s secrets which were created by pentagon
-	secretsList, err := r.secretsClient.List(ctx, metav1.ListOptions{
-		LabelSelector: labels.Set{LabelKey: r.labelValue}.String(),
-	})
-	if err != nil {
-		return fmt.Errorf("error listing secrets: %s", err)
+	_, err := r.ReflectResults(ctx, mappings)
+	return err
+}
+
+// ReflectResults is like Reflect, but also returns the outcome of every
+// mapping that was processed, followed by any secrets deleted during
+// reconciliation.  Clusters that couldn't be reached have a failed result
+// of their own, and are skipped without affecting the others.  When the
+// reflector was created with WithContinueOnError the returned error joins
+// the errors of all the failed mappings.
+func (r *Reflector) ReflectResults(ctx context.Context, mappings []Mapping) ([]MappingResult, error) {
+	results, _, err := r.reflect(ctx, mappings, mappings, r.continueOnError)
+	return results, err
+}
+
+// reflect syncs the secrets described by mappings, then reconciles against
+// owned, the complete set of mappings that pentagon is responsible for.
+// owned may be a superset of mappings when only some of them are due for a
+// refresh.  Unless continueOnError is set, a failure stops the clusters that
+// it happened in, and reflect returns once every cluster has stopped.  The
+// returned failed has an entry for each of mappings, set if it failed in any
+// cluster, including clusters that couldn't be reached.
+func (r *Reflector) reflect(
+	ctx context.Context,
+	mappings []Mapping,
+	owned []Mapping,
+	continueOnError bool,
+) ([]MappingResult, []bool, error) {
+	results := make([]MappingResult, 0, len(mappings))
+	failed := make([]bool, len(mappings))
+	var errs []error
+
+	// fail records the error of a failed result in its cluster, returning
+	// true if there are no clusters left to write to
+	fail := func(c *cluster, err error) bool {
+		errs = append(errs, c.wrap(err))
+		if continueOnError {
+			return false
+		}
+		c.stopped = true
+		return !slices.ContainsFunc(r.clusters, func(c *cluster) bool { return !c.stopped })
 	}
-	r.secretsSet = make(map[string]struct{}, secretsList.Size())
-	for _, secret := range secretsList.Items {
-		r.secretsSet[secret.ObjectMeta.Name] = struct{}{}
+
+	for _, c := range r.clusters {
+		c.reset()
+		if err := r.listSecrets(ctx, c, owned); err != nil {
+			results = append(results, MappingResult{
+				Cluster: c.Name,
+				Action:  ActionFailed,
+				DryRun:  r.dryRun,
+				Err:     err,
+			})
+			// an unreachable cluster is never reflected to, but doesn't
+			// stop the others
+			errs = append(errs, c.wrap(err))
+			c.stopped = true
+			for i, mapping := range mappings {
+				failed[i] = failed[i] || c.targets(mapping)
+			}
+		}
+	}
+	if !slices.ContainsFunc(r.clusters, func(c *cluster) bool { return !c.stopped }) {
+		return results, failed, stderrors.Join(errs...)
 	}
 
-	// make a set of the secrets that we're updating so we can reconcile later.
-	touchedSecrets := map[string]struct{}{}
+	// list mappings are listed once, and expanded into the mappings of the
+	// secrets they list
+	listings := map[string]listing{}
 
-	for _, mapping := range mappings {
-		var k8sSecretData map[string][]byte
-		var msg string
-		switch mapping.SourceType {
-		case GSMSourceType:
-			var err error
-			k8sSecretData, err = r.getGSMSecret(ctx, mapping)
-			if err != nil {
-				return err
+	for i, mapping := range mappings {
+		var clusters []*cluster
+		for _, c := range r.clusters {
+			if !c.stopped && c.targets(mapping) {
+				clusters = append(clusters, c)
 			}
-			msg = fmt.Sprintf(
-				"reflected GSM secret %s to kubernetes secret %s (type %s)",
-				mapping.Path,
-				mapping.SecretName,
-				mapping.SecretType,
-			)
-		case VaultSourceType:
+		}
+		if len(clusters) == 0 {
+			continue
+		}
+
+		expanded := []Mapping{mapping}
+		if isListMapping(mapping) {
 			var err error
-			k8sSecretData, err = r.getVaultSecret(mapping)
+			expanded, err = r.expandListMapping(ctx, mapping, listings)
 			if err != nil {
-				return err
+				failed[i] = true
+				r.metrics.Error(metrics.PhaseFetch, "", "", "")
+				if continueOnError {
+					err = fmt.Errorf("error listing %s: %w", mapping.Path, err)
+				}
+				for _, c := range clusters {
+					results = append(results, MappingResult{
+						Cluster:    c.Name,
+						SourceType: mapping.SourceType,
+						SourcePath: mapping.Path,
+						Action:     ActionFailed,
+						DryRun:     r.dryRun,
+						Err:        err,
+					})
+					if fail(c, err) {
+						return results, failed, stderrors.Join(errs...)
+					}
+				}
+				continue
 			}
-			msg = fmt.Sprintf(
-				"reflected vault secret %s to kubernetes secret %s (type %s)",
-				mapping.Path,
-				mapping.SecretName,
-				mapping.SecretType,
-			)
-		default:
-			return fmt.Errorf("unknown secret source type: %s", mapping.SourceType)
 		}
 
-		if err := r.createK8sSecret(ctx, mapping, k8sSecretData); err != nil {
-			return err
-		}
+		for _, m := range expanded {
+			for _, result := range r.reflectMapping(ctx, m, clusters) {
+				results = append(results, result)
+				if result.Err == nil {
+					continue
+				}
+				failed[i] = true
 
-		// record the fact that we updated it
-		log.Println(msg)
-		touchedSecrets[mapping.SecretName] = struct{}{}
+				c := clusters[slices.IndexFunc(clusters, func(c *cluster) bool {
+					return c.Name == result.Cluster
+				})]
+				err := result.Err
+				if continueOnError {
+					err = fmt.Errorf(
+						"error reflecting %s to kubernetes secret %s: %w",
+						result.SourcePath,
+						types.NamespacedName{Namespace: result.Namespace, Name: m.SecretName},
+						result.Err,
+					)
+				}
+				if fail(c, err) {
+					return results, failed, stderrors.Join(errs...)
+				}
+			}
+		}
 	}
 
 	// if we're not using the default label value, delete any secrets that are no longer in our
-	// mappings, but might still exist from previous runs in kubernetes
+	// mappings, but might still exist from previous runs in kubernetes.  Note that the secrets
+	// of mappings which failed are still owned, so they're never deleted just because their
+	// source couldn't be read.
 	if r.labelValue != DefaultLabelValue {
-		if err := r.reconcile(ctx, r.secretsSet, touchedSecrets); err != nil {
-			return fmt.Errorf("error reconciling: %s", err)
+		for _, c := range r.clusters {
+			if c.stopped {
+				continue
+			}
+			ownedSecrets, err := r.ownedSecrets(ctx, c, owned, listings)
+			if err == nil {
+				var deleted []MappingResult
+				deleted, err = r.reconcile(ctx, c, c.secretsSet, ownedSecrets, continueOnError)
+				results = append(results, deleted...)
+			}
+			if err != nil {
+				errs = append(errs, c.wrap(fmt.Errorf("error reconciling: %w", err)))
+			}
 		}
 	}
 
+	return results, failed, stderrors.Join(errs...)
+}
+
+// listSecrets fills in the existing kubernetes secrets in c which were
+// created by pentagon, in every namespace that owned is written to.
+func (r *Reflector) listSecrets(ctx context.Context, c *cluster, owned []Mapping) error {
+	namespaces, err := r.managedNamespaces(ctx, c, owned)
+	if err != nil {
+		r.metrics.Error(metrics.PhaseList, c.Name, "", "")
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		secretsList, err := c.secrets(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.Set{LabelKey: r.labelValue}.String(),
+		})
+		if err != nil {
+			r.metrics.Error(metrics.PhaseList, c.Name, namespace, "")
+			return fmt.Errorf("error listing secrets in namespace %s: %s", namespace, err)
+		}
+		for _, secret := range secretsList.Items {
+			c.secretsSet[types.NamespacedName{Namespace: namespace, Name: secret.Name}] = secret
+		}
+	}
 	return nil
 }
 
-func (r *Reflector) getVaultSecret(mapping Mapping) (map[string][]byte, error) {
-	secretData, err := r.vaultClient.Read(mapping.Path)
+// ownedSecrets returns the namespaced names of the kubernetes secrets of
+// mappings in c.  The secrets generated by list mappings that couldn't be
+// listed are identified by their AnnotationVaultListPath or
+// AnnotationGSMFilterPath annotation instead.
+func (r *Reflector) ownedSecrets(
+	ctx context.Context,
+	c *cluster,
+	mappings []Mapping,
+	listings map[string]listing,
+) (map[types.NamespacedName]struct{}, error) {
+	// unlisted are the list paths of the list mappings that couldn't be
+	// listed, in each of their namespaces
+	type unlistedKey struct {
+		namespace  string
+		annotation string
+		listPath   string
+	}
+
+	owned := make(map[types.NamespacedName]struct{}, len(mappings))
+	unlisted := map[unlistedKey]struct{}{}
+	for _, mapping := range mappings {
+		if !c.targets(mapping) {
+			continue
+		}
+		namespaces, err := r.mappingNamespaces(ctx, c, mapping)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isListMapping(mapping) {
+			for _, namespace := range namespaces {
+				owned[types.NamespacedName{Namespace: namespace, Name: mapping.SecretName}] = struct{}{}
+			}
+			continue
+		}
+
+		expanded, err := r.expandListMapping(ctx, mapping, listings)
+		if err != nil {
+			for _, namespace := range namespaces {
+				unlisted[unlistedKey{
+					namespace:  namespace,
+					annotation: listPathAnnotation(mapping.SourceType),
+					listPath:   mapping.Path,
+				}] = struct{}{}
+			}
+			continue
+		}
+		for _, m := range expanded {
+			for _, namespace := range namespaces {
+				owned[types.NamespacedName{Namespace: namespace, Name: m.SecretName}] = struct{}{}
+			}
+		}
+	}
+
+	for key, secret := range c.secretsSet {
+		for _, annotation := range []string{AnnotationVaultListPath, AnnotationGSMFilterPath} {
+			listPath, ok := secret.Annotations[annotation]
+			if !ok {
+				continue
+			}
+			if _, ok := unlisted[unlistedKey{namespace: key.Namespace, annotation: annotation, listPath: listPath}]; ok {
+				owned[key] = struct{}{}
+			}
+		}
+	}
+	return owned, nil
+}
+
+// reflectMapping syncs a single mapping into its kubernetes secret in each
+// of its namespaces in each of clusters.  The mapping's data is only fetched
+// once, and there's a result for each cluster and namespace.
+func (r *Reflector) reflectMapping(ctx context.Context, mapping Mapping, clusters []*cluster) []MappingResult {
+	result := MappingResult{
+		SourceType: mappingSourceType(mapping),
+		SourcePath: mappingSourcePath(mapping),
+		SecretName: mapping.SecretName,
+		Action:     ActionFailed,
+		DryRun:     r.dryRun,
+	}
+
+	var results []MappingResult
+	namespaces := make(map[*cluster][]string, len(clusters))
+	for _, c := range clusters {
+		ns, err := r.mappingNamespaces(ctx, c, mapping)
+		if err != nil {
+			r.metrics.Error(metrics.PhaseList, c.Name, "", mapping.SecretName)
+			result := result
+			result.Cluster = c.Name
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		if len(ns) == 0 {
+			log.Printf(
+				"WARNING: no namespaces match %q, so %s isn't reflected",
+				mapping.Namespaces.Selector,
+				describeSource(mapping),
+			)
+			continue
+		}
+		namespaces[c] = ns
+	}
+	if len(namespaces) == 0 {
+		return results
+	}
+
+	k8sSecretData, annotations, err := r.fetchMapping(ctx, mapping)
+	if err != nil {
+		r.metrics.Error(metrics.PhaseFetch, "", "", mapping.SecretName)
+		for _, c := range clusters {
+			for _, namespace := range namespaces[c] {
+				result.Cluster = c.Name
+				result.Namespace = namespace
+				result.Err = err
+				results = append(results, result)
+			}
+		}
+		return results
+	}
+
+	if mapping.listPath != "" {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[listPathAnnotation(mapping.SourceType)] = mapping.listPath
+	}
+
+	for _, c := range clusters {
+		for _, namespace := range namespaces[c] {
+			result.Cluster = c.Name
+			result.Namespace = namespace
+			results = append(results, r.reflectSecret(ctx, c, mapping, result, k8sSecretData, maps.Clone(annotations)))
+		}
+	}
+	return results
+}
+
+// reflectSecret writes the kubernetes secret of mapping in result.Namespace
+// of c, filling in the rest of result.
+func (r *Reflector) reflectSecret(
+	ctx context.Context,
+	c *cluster,
+	mapping Mapping,
+	result MappingResult,
+	k8sSecretData map[string][]byte,
+	annotations map[string]string,
+) MappingResult {
+	key := types.NamespacedName{Namespace: result.Namespace, Name: mapping.SecretName}
+
+	// mappings selecting namespaces by label can overlap in ways that can't
+	// be caught when the config is validated
+	if other, ok := c.written[key]; ok {
+		r.metrics.Error(metrics.PhaseFetch, c.Name, result.Namespace, mapping.SecretName)
+		result.Err = fmt.Errorf(
+			"%s and %s would both be written to kubernetes secret %s",
+			describeSource(other),
+			describeSource(mapping),
+			key,
+		)
+		return result
+	}
+	c.written[key] = mapping
+
+	// the previous owner of adopted secrets is kept for as long as pentagon
+	// manages them
+	existing, exists := c.secretsSet[key]
+	if owner, ok := existing.Annotations[AnnotationPreviousOwner]; exists && ok {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationPreviousOwner] = owner
+	}
+
+	secret := r.newK8sSecret(mapping, result.Namespace, k8sSecretData, annotations)
+	if exists {
+		// only the fields that pentagon applied are compared, so that
+		// changes other field managers made don't cause an update.
+		err := r.upgradeManagedFields(ctx, c, &existing)
+		if err != nil {
+			r.metrics.Error(metrics.PhaseUpdate, c.Name, result.Namespace, mapping.SecretName)
+			result.Err = err
+			return result
+		}
+		managed, err := managedSecret(&existing)
+		if err != nil {
+			r.metrics.Error(metrics.PhaseUpdate, c.Name, result.Namespace, mapping.SecretName)
+			result.Err = err
+			return result
+		}
+		result.Diff = diffSecrets(managed, secret)
+	}
+
+	action, err := r.createK8sSecret(ctx, c, mapping.ConflictPolicy, secret, result.Diff)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Action = action
+
+	if action == ActionSkipped {
+		result.Warning = fmt.Sprintf(
+			"kubernetes secret %s already exists and isn't managed by pentagon, so it was skipped",
+			c.describe(key),
+		)
+		return result
+	}
+	if r.dryRun {
+		return result
+	}
+
+	r.metrics.Synced(c.Name, result.Namespace, mapping.SecretName, result.SourceType, time.Now())
+	if action == ActionUnchanged {
+		log.Printf(
+			"%s is unchanged in kubernetes secret %s",
+			describeSource(mapping),
+			c.describe(key),
+		)
+		return result
+	}
+	log.Printf(
+		"reflected %s to kubernetes secret %s (type %s)",
+		describeSource(mapping),
+		c.describe(key),
+		mapping.SecretType,
+	)
+	return result
+}
+
+// fetchMapping reads the data for mapping from its source, or merges the
+// data of its sources, recording how long each fetch took.  The data is then
+// decoded, selected by the mapping's key rules, rendered by its templates and
+// serialized in its output format, for whichever of those it has.
+func (r *Reflector) fetchMapping(ctx context.Context, mapping Mapping) (map[string][]byte, map[string]string, error) {
+	var data map[string][]byte
+	var annotations map[string]string
+	var err error
+	if len(mapping.Sources) > 0 {
+		data, annotations, err = r.fetchSources(ctx, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		fetchStart := time.Now()
+		data, annotations, err = r.fetch(ctx, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.metrics.ObserveFetch(mapping.SourceType, time.Since(fetchStart))
+	}
+
+	data, err = decodeData(mapping, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding %s: %w", describeSource(mapping), err)
+	}
+
+	data, err = mapping.Keys.apply(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error applying key rules to %s: %w", describeSource(mapping), err)
+	}
+
+	if len(mapping.Templates) > 0 {
+		data, err = renderTemplates(mapping.Templates, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error rendering %s: %w", describeSource(mapping), err)
+		}
+	}
+
+	if mapping.OutputFormat != "" {
+		formatted, err := formatData(mapping.OutputFormat, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error formatting %s: %w", describeSource(mapping), err)
+		}
+		key := mapping.OutputKey
+		if key == "" {
+			key = defaultOutputKeys[mapping.OutputFormat]
+		}
+		data = map[string][]byte{key: formatted}
+	}
+	return data, annotations, nil
+}
+
+// fetch reads the data for mapping from its source, along with any
+// annotations describing it.
+func (r *Reflector) fetch(ctx context.Context, mapping Mapping) (map[string][]byte, map[string]string, error) {
+	var data map[string][]byte
+	var err error
+	switch mapping.SourceType {
+	case GSMSourceType:
+		data, err = r.getGSMSecret(ctx, mapping)
+	case VaultSourceType:
+		return r.getVaultSecret(mapping)
+	case AWSSourceType:
+		data, err = r.getAWSSecret(ctx, mapping)
+	case AzureSourceType:
+		data, err = r.getAzureSecret(ctx, mapping)
+	default:
+		err = fmt.Errorf("unknown secret source type: %s", mapping.SourceType)
+	}
+	return data, nil, err
+}
+
+func (r *Reflector) getVaultSecret(mapping Mapping) (map[string][]byte, map[string]string, error) {
+	logical, err := r.vaultLogical(mapping)
 	if err != nil {
-		return nil, fmt.Errorf("error reading vault key '%s': %s", mapping.Path, err)
+		return nil, nil, err
+	}
+
+	path := mapping.Path
+	engineType := mapping.VaultEngineType
+	if engineType == vault.EngineTypeAuto {
+		mount, err := r.vaultMounts.Resolve(logical, mapping.VaultNamespace, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		engineType = mount.EngineType
+		path = mount.APIPath(path)
+	}
+	if mapping.VaultVersion != 0 && engineType != vault.EngineTypeKeyValueV2 {
+		return nil, nil, fmt.Errorf(
+			"vault version %d of %s requested, but versions require a key/value v2 engine",
+			mapping.VaultVersion,
+			path,
+		)
+	}
+
+	var secretData *api.Secret
+	if mapping.VaultVersion != 0 {
+		dataReader, ok := logical.(vault.DataReader)
+		if !ok {
+			return nil, nil, fmt.Errorf(
+				"vault client does not support reading versions (version %d of %s)",
+				mapping.VaultVersion,
+				path,
+			)
+		}
+		secretData, err = dataReader.ReadWithData(path, map[string][]string{
+			"version": {strconv.Itoa(mapping.VaultVersion)},
+		})
+	} else {
+		secretData, err = logical.Read(path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading vault key '%s': %s", path, err)
 	}
 
 	if secretData == nil {
-		return nil, fmt.Errorf("secret %s not found", mapping.Path)
+		if mapping.VaultVersion != 0 {
+			return nil, nil, fmt.Errorf("version %d of secret %s not found", mapping.VaultVersion, path)
+		}
+		return nil, nil, fmt.Errorf("secret %s not found", path)
 	}
 
 	// convert map[string]interface{} to map[string][]byte
 	var k8sSecretData map[string][]byte
-	switch mapping.VaultEngineType {
+	var annotations map[string]string
+	switch engineType {
 	case vault.EngineTypeKeyValueV1:
-		k8sSecretData, err = r.castData(secretData.Data)
+		k8sSecretData, err = r.castData(secretData.Data, mapping.VaultNestedValues)
 		if err != nil {
-			return nil, fmt.Errorf("error casting data: %s", err)
+			return nil, nil, fmt.Errorf("error casting data: %s", err)
 		}
 	case vault.EngineTypeKeyValueV2:
+		metadata, _ := secretData.Data["metadata"].(map[string]any)
+		if err := checkVaultVersion(path, metadata); err != nil {
+			return nil, nil, err
+		}
+
 		// there's an extra level of wrapping with the v2 kv secrets engine
 		if unwrapped, ok := secretData.Data["data"].(map[string]any); ok {
-			k8sSecretData, err = r.castData(unwrapped)
+			k8sSecretData, err = r.castData(unwrapped, mapping.VaultNestedValues)
 			if err != nil {
-				return nil, fmt.Errorf("error casting data: %s", err)
+				return nil, nil, fmt.Errorf("error casting data: %s", err)
 			}
 		} else {
-			return nil, fmt.Errorf("key/value v2 interface did not have expected extra wrapping")
+			return nil, nil, fmt.Errorf("key/value v2 interface did not have expected extra wrapping (vaultEngineType %q detects the engine type)", vault.EngineTypeAuto)
+		}
+
+		annotations, err = vaultMetadataAnnotations(metadata)
+		if err != nil {
+			return nil, nil, err
 		}
 	default:
-		return nil, fmt.Errorf("unknown vault engine type: %q", mapping.VaultEngineType)
+		return nil, nil, fmt.Errorf("unknown vault engine type: %q", engineType)
 	}
 
-	return k8sSecretData, nil
+	return k8sSecretData, annotations, nil
+}
+
+// checkVaultVersion returns an error if the metadata of the key/value v2
+// secret at path says that the version that was read has been deleted or
+// destroyed, in which case vault doesn't return its data.
+func checkVaultVersion(path string, metadata map[string]any) error {
+	version := fmt.Sprint(metadata["version"])
+	if destroyed, _ := metadata["destroyed"].(bool); destroyed {
+		return fmt.Errorf("version %s of secret %s has been destroyed", version, path)
+	}
+	if deleted, _ := metadata["deletion_time"].(string); deleted != "" {
+		return fmt.Errorf("version %s of secret %s was deleted at %s", version, path, deleted)
+	}
+	return nil
+}
+
+// vaultMetadataAnnotations returns the annotations describing a key/value v2
+// secret with metadata.
+func vaultMetadataAnnotations(metadata map[string]any) (map[string]string, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+
+	annotations := map[string]string{}
+	if version, ok := metadata["version"]; ok && version != nil {
+		annotations[AnnotationVaultVersion] = fmt.Sprint(version)
+	}
+	if created, ok := metadata["created_time"].(string); ok && created != "" {
+		annotations[AnnotationVaultCreatedTime] = created
+	}
+	if custom, ok := metadata["custom_metadata"].(map[string]any); ok && len(custom) > 0 {
+		encoded, err := json.Marshal(custom)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding custom metadata: %s", err)
+		}
+		annotations[AnnotationVaultCustomMetadata] = string(encoded)
+	}
+	return annotations, nil
+}
+
+// vaultLogical returns the vault client to read mapping's secret with, which
+// makes requests in the mapping's namespace.
+func (r *Reflector) vaultLogical(mapping Mapping) (vault.Logical, error) {
+	if mapping.VaultNamespace == "" {
+		return r.vaultClient, nil
+	}
+
+	namespacer, ok := r.vaultClient.(vault.Namespacer)
+	if !ok {
+		return nil, fmt.Errorf(
+			"vault client does not support namespaces (namespace %q)",
+			mapping.VaultNamespace,
+		)
+	}
+	return namespacer.WithNamespace(mapping.VaultNamespace), nil
 }
 
 func (r *Reflector) getGSMSecret(ctx context.Context, mapping Mapping) (map[string][]byte, error) {
@@ -162,19 +825,10 @@ func (r *Reflector) getGSMSecret(ctx context.Context, mapping Mapping) (map[stri
 	}
 
 	if mapping.GSMEncodingType == GSMEncodingTypeJSON {
-		var unmarshaled map[string]json.RawMessage
-		if err := json.Unmarshal(resp.Payload.Data, &unmarshaled); err != nil {
+		casted, err := unwrapJSON(resp.Payload.Data)
+		if err != nil {
 			return nil, fmt.Errorf("error unmarshaling GSM JSON secret %q: %w", mapping.Path, err)
 		}
-		casted := make(map[string][]byte, len(unmarshaled))
-		for k, v := range unmarshaled {
-			var stringVal string
-			if err := json.Unmarshal(v, &stringVal); err == nil {
-				casted[k] = []byte(stringVal)
-				continue
-			}
-			casted[k] = v
-		}
 		return casted, nil
 	}
 
@@ -186,7 +840,123 @@ func (r *Reflector) getGSMSecret(ctx context.Context, mapping Mapping) (map[stri
 	return map[string][]byte{keyName: resp.Payload.Data}, nil
 }
 
-func (r *Reflector) createK8sSecret(ctx context.Context, mapping Mapping, data map[string][]byte) error {
+func (r *Reflector) getAWSSecret(ctx context.Context, mapping Mapping) (map[string][]byte, error) {
+	if r.awsClient == nil {
+		return nil, fmt.Errorf("no AWS Secrets Manager client configured for secret %q", mapping.Path)
+	}
+
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(mapping.Path),
+	}
+	if mapping.AWSVersionStage != "" {
+		input.VersionStage = aws.String(mapping.AWSVersionStage)
+	}
+	if mapping.AWSVersionID != "" {
+		input.VersionId = aws.String(mapping.AWSVersionID)
+	}
+
+	// secrets referenced by ARN may live in a different region than the
+	// client's default, so talk to the secret's own region.
+	var opts []func(*secretsmanager.Options)
+	if secretARN, err := arn.Parse(mapping.Path); err == nil && secretARN.Region != "" {
+		opts = append(opts, func(o *secretsmanager.Options) {
+			o.Region = secretARN.Region
+		})
+	}
+
+	resp, err := r.awsClient.GetSecretValue(ctx, input, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing AWS secret %q: %w", mapping.Path, err)
+	}
+
+	var payload []byte
+	switch {
+	case resp.SecretString != nil:
+		payload = []byte(*resp.SecretString)
+	case resp.SecretBinary != nil:
+		payload = resp.SecretBinary
+	default:
+		return nil, fmt.Errorf("AWS secret %q has no value", mapping.Path)
+	}
+
+	if mapping.AWSEncodingType == AWSEncodingTypeJSON {
+		casted, err := unwrapJSON(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling AWS JSON secret %q: %w", mapping.Path, err)
+		}
+		return casted, nil
+	}
+
+	keyName := mapping.AWSSecretKeyValue
+	if keyName == "" {
+		keyName = mapping.SecretName
+	}
+
+	return map[string][]byte{keyName: payload}, nil
+}
+
+func (r *Reflector) getAzureSecret(ctx context.Context, mapping Mapping) (map[string][]byte, error) {
+	if r.azureClient == nil {
+		return nil, fmt.Errorf("no Azure Key Vault client configured for secret %q", mapping.Path)
+	}
+
+	secret, err := r.azureClient.GetSecret(ctx, mapping.AzureVaultURL, mapping.Path, mapping.AzureVersion)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error accessing Azure secret %q in %s: %w",
+			mapping.Path,
+			mapping.AzureVaultURL,
+			err,
+		)
+	}
+
+	if mapping.AzureObjectType == AzureObjectTypeCertificate {
+		cert, key, err := azurekv.CertificateToTLS(secret)
+		if err != nil {
+			return nil, fmt.Errorf("error converting Azure certificate %q: %w", mapping.Path, err)
+		}
+		return map[string][]byte{
+			corev1.TLSCertKey:       cert,
+			corev1.TLSPrivateKeyKey: key,
+		}, nil
+	}
+
+	keyName := mapping.AzureSecretKeyValue
+	if keyName == "" {
+		keyName = mapping.SecretName
+	}
+
+	return map[string][]byte{keyName: []byte(secret.Value)}, nil
+}
+
+// unwrapJSON turns a JSON object into kubernetes secret data.  String values
+// are stored without quoting, and all other values are stored as their JSON
+// serialization.
+func unwrapJSON(data []byte) (map[string][]byte, error) {
+	var unmarshaled map[string]json.RawMessage
+	if err := json.Unmarshal(data, &unmarshaled); err != nil {
+		return nil, err
+	}
+	casted := make(map[string][]byte, len(unmarshaled))
+	for k, v := range unmarshaled {
+		var stringVal string
+		if err := json.Unmarshal(v, &stringVal); err == nil {
+			casted[k] = []byte(stringVal)
+			continue
+		}
+		casted[k] = v
+	}
+	return casted, nil
+}
+
+// newK8sSecret builds the kubernetes secret that mapping should produce in
+// namespace from data.
+func (r *Reflector) newK8sSecret(
+	mapping Mapping,
+	namespace string,
+	data map[string][]byte,
+	annotations map[string]string,
+) *corev1.Secret {
 	labels := make(map[string]string)
 	if mapping.AdditionalSecretLabels != nil {
 		labels = maps.Clone(mapping.AdditionalSecretLabels)
@@ -194,71 +964,232 @@ func (r *Reflector) createK8sSecret(ctx context.Context, mapping Mapping, data m
 
 	labels[LabelKey] = r.labelValue
 
-	secret := &corev1.Secret{
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      mapping.SecretName,
-			Namespace: r.k8sNamespace,
-			Labels:    labels,
+			Name:        mapping.SecretName,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 		Data: data,
 		Type: mapping.SecretType,
 	}
+}
 
-	if _, ok := r.secretsSet[mapping.SecretName]; ok {
-		// secret already exists, so we should update it
-		_, err := r.secretsClient.Update(ctx, secret, metav1.UpdateOptions{})
-		if err != nil {
-			return fmt.Errorf("error updating secret: %s", err)
+func (r *Reflector) createK8sSecret(
+	ctx context.Context,
+	c *cluster,
+	conflictPolicy string,
+	secret *corev1.Secret,
+	diff *Diff,
+) (Action, error) {
+	key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	if _, ok := c.secretsSet[key]; ok {
+		// skip no-op updates, which would otherwise bump the resourceVersion
+		// and trigger watchers of the secret.
+		if diff.Empty() {
+			return ActionUnchanged, nil
 		}
-	} else {
-		// secret doesn't exist, so create it
-		_, err := r.secretsClient.Create(ctx, secret, metav1.CreateOptions{})
-		if err != nil {
-			return fmt.Errorf("error creating secret: %s", err)
+		if r.dryRun && !r.serverDryRun {
+			return ActionUpdated, nil
+		}
+
+		// secret already exists, so we should update it
+		if err := r.applySecret(ctx, c, secret, r.forceConflicts); err != nil {
+			r.metrics.Error(metrics.PhaseUpdate, c.Name, secret.Namespace, secret.Name)
+			return ActionFailed, fmt.Errorf("error updating secret: %s", err)
 		}
+		return ActionUpdated, nil
+	}
+
+	// applying would silently take over a secret that pentagon doesn't
+	// manage, so check for one first.
+	existing, err := c.secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
+	if err == nil {
+		return r.resolveConflict(ctx, c, conflictPolicy, existing, secret)
+	}
+	if !errors.IsNotFound(err) {
+		r.metrics.Error(metrics.PhaseCreate, c.Name, secret.Namespace, secret.Name)
+		return ActionFailed, fmt.Errorf("error getting secret: %s", err)
+	}
+	if r.dryRun && !r.serverDryRun {
+		return ActionCreated, nil
+	}
+
+	// secret doesn't exist, so create it
+	if err := r.applySecret(ctx, c, secret, r.forceConflicts); err != nil {
+		r.metrics.Error(metrics.PhaseCreate, c.Name, secret.Namespace, secret.Name)
+		return ActionFailed, fmt.Errorf("error creating secret: %s", err)
+	}
+	return ActionCreated, nil
+}
+
+// dryRunOptions returns the DryRun value to pass in kubernetes API requests.
+func (r *Reflector) dryRunOptions() []string {
+	if r.serverDryRun {
+		return []string{metav1.DryRunAll}
 	}
 	return nil
 }
 
-// reconcile deletes any secrets that were not part of the mapping (but still present in the secrets
-// with the same label)
+// reconcile deletes any secrets in c that were not part of the mapping (but still present in the
+// secrets with the same label).  Unless continueOnError is set, it stops at the first failure.
 func (r *Reflector) reconcile(
 	ctx context.Context,
-	allSecrets map[string]struct{},
-	touchedSecrets map[string]struct{},
-) error {
-	for secret := range allSecrets {
-		if _, found := touchedSecrets[secret]; !found {
+	c *cluster,
+	allSecrets map[types.NamespacedName]corev1.Secret,
+	ownedSecrets map[types.NamespacedName]struct{},
+	continueOnError bool,
+) ([]MappingResult, error) {
+	var results []MappingResult
+	var errs []error
+	for _, key := range slices.SortedFunc(maps.Keys(allSecrets), compareNamespacedNames) {
+		if _, found := ownedSecrets[key]; !found {
+			if r.dryRun && !r.serverDryRun {
+				results = append(results, MappingResult{
+					Cluster:    c.Name,
+					Namespace:  key.Namespace,
+					SecretName: key.Name,
+					Action:     ActionDeleted,
+					DryRun:     true,
+				})
+				continue
+			}
+
 			// it was in the list, but we didn't update it (or create it)
-			err := r.secretsClient.Delete(ctx, secret, metav1.DeleteOptions{})
+			err := c.secrets(key.Namespace).Delete(ctx, key.Name, metav1.DeleteOptions{
+				DryRun: r.dryRunOptions(),
+			})
 
 			// not found is ok, since we're deleting the secret
 			if err != nil && !errors.IsNotFound(err) {
-				return err
+				r.metrics.Error(metrics.PhaseReconcileDelete, c.Name, key.Namespace, key.Name)
+				results = append(results, MappingResult{
+					Cluster:    c.Name,
+					Namespace:  key.Namespace,
+					SecretName: key.Name,
+					Action:     ActionFailed,
+					DryRun:     r.dryRun,
+					Err:        err,
+				})
+				if !continueOnError {
+					return results, err
+				}
+				errs = append(errs, err)
+				continue
 			}
+			results = append(results, MappingResult{
+				Cluster:    c.Name,
+				Namespace:  key.Namespace,
+				SecretName: key.Name,
+				Action:     ActionDeleted,
+				DryRun:     r.dryRun,
+			})
 		}
 	}
 
-	return nil
+	return results, stderrors.Join(errs...)
+}
+
+// compareNamespacedNames orders namespaced names by namespace, then name.
+func compareNamespacedNames(a, b types.NamespacedName) int {
+	return cmp.Or(
+		strings.Compare(a.Namespace, b.Namespace),
+		strings.Compare(a.Name, b.Name),
+	)
 }
 
-// castData turns vault map[string]interface{}'s into map[string][]byte's
+// castData turns vault map[string]interface{}'s into map[string][]byte's.
+// Strings are written as they are, and other JSON values in their canonical
+// JSON form.  Nested objects and arrays are either written as JSON or, if
+// nestedValues is NestedValuesFlatten, flattened into dotted keys.
 func (r *Reflector) castData(
 	innerData map[string]any,
+	nestedValues string,
 ) (map[string][]byte, error) {
 
 	k8sSecretData := make(map[string][]byte, len(innerData))
 
 	for k, v := range innerData {
-		switch casted := v.(type) {
-		case string:
-			k8sSecretData[k] = []byte(casted)
-		case []byte:
-			k8sSecretData[k] = casted
-		default:
-			return nil, fmt.Errorf("unknown type of secret %T", v)
+		if err := castValue(k8sSecretData, k, v, nestedValues); err != nil {
+			return nil, err
 		}
 	}
 
 	return k8sSecretData, nil
 }
+
+// castValue adds the value v of key to data, recursing into nested objects
+// and arrays when nestedValues is NestedValuesFlatten.  Values of a type that
+// can't be written are reported by their key and Go type.
+func castValue(data map[string][]byte, key string, v any, nestedValues string) error {
+	var value []byte
+	switch casted := v.(type) {
+	case string:
+		value = []byte(casted)
+	case []byte:
+		value = casted
+	case json.Number:
+		value = []byte(casted.String())
+	case bool:
+		value = []byte(strconv.FormatBool(casted))
+	case nil:
+		value = []byte("null")
+	case map[string]any:
+		if nestedValues == NestedValuesFlatten && len(casted) > 0 {
+			for k, nested := range casted {
+				if err := castValue(data, key+"."+k, nested, nestedValues); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		encoded, err := encodeJSONValue(casted)
+		if err != nil {
+			return fmt.Errorf("error encoding key %q: %s", key, err)
+		}
+		value = encoded
+	case []any:
+		if nestedValues == NestedValuesFlatten && len(casted) > 0 {
+			for i, nested := range casted {
+				if err := castValue(data, key+"."+strconv.Itoa(i), nested, nestedValues); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		encoded, err := encodeJSONValue(casted)
+		if err != nil {
+			return fmt.Errorf("error encoding key %q: %s", key, err)
+		}
+		value = encoded
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		// the vault client decodes numbers as json.Number, but other
+		// clients may not
+		encoded, err := encodeJSONValue(casted)
+		if err != nil {
+			return fmt.Errorf("error encoding key %q: %s", key, err)
+		}
+		value = encoded
+	default:
+		return fmt.Errorf("unknown type of secret %T for key %q", v, key)
+	}
+
+	if _, ok := data[key]; ok {
+		return fmt.Errorf("key %q is in the secret more than once after flattening", key)
+	}
+	data[key] = value
+	return nil
+}
+
+// encodeJSONValue encodes v as compact JSON, without escaping HTML
+// characters, so that it reads the same as a JSON value in a GSM secret.
+func encodeJSONValue(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
@@ -1,18 +1,40 @@
 package pentagon
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	stderrors "errors"
+	"math/big"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"maps"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
+	"github.com/vimeo/pentagon/awssm"
+	"github.com/vimeo/pentagon/azurekv"
 	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/metrics"
 	"github.com/vimeo/pentagon/vault"
 )
 
@@ -31,7 +53,7 @@ func TestReflectorSimple(t *testing.T) {
 	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
 		ctx := context.Background()
 
-		k8sClient := k8sfake.NewSimpleClientset()
+		k8sClient := k8sfake.NewClientset()
 		vaultClient := vault.NewMock(map[string]vault.EngineType{
 			"secrets": engineType,
 		})
@@ -90,7 +112,7 @@ func TestReflectorAdditionalSecretLabelsVault(t *testing.T) {
 	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
 		ctx := context.Background()
 
-		k8sClient := k8sfake.NewSimpleClientset()
+		k8sClient := k8sfake.NewClientset()
 		vaultClient := vault.NewMock(map[string]vault.EngineType{
 			"secrets": engineType,
 		})
@@ -150,7 +172,7 @@ func TestReflectorDefaultLabelOverwriteVault(t *testing.T) {
 	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
 		ctx := context.Background()
 
-		k8sClient := k8sfake.NewSimpleClientset()
+		k8sClient := k8sfake.NewClientset()
 		vaultClient := vault.NewMock(map[string]vault.EngineType{
 			"secrets": engineType,
 		})
@@ -198,7 +220,7 @@ func TestReflectorDefaultLabelOverwriteVault(t *testing.T) {
 
 func TestReflectorGSM(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	gsm := gsm.NewMockGSM(map[string][]byte{
 		"projects/foo/secrets/bar/versions/latest": []byte("foo_bar_latest"),
@@ -245,7 +267,7 @@ func TestReflectorGSM(t *testing.T) {
 
 func TestReflectorAdditionalSecretLabelsGSM(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	gsm := gsm.NewMockGSM(map[string][]byte{
 		"projects/foo/secrets/bar/versions/latest": []byte("foo_bar_latest"),
@@ -298,7 +320,7 @@ func TestReflectorAdditionalSecretLabelsGSM(t *testing.T) {
 
 func TestReflectorDefaultLabelOverwriteGSM(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	gsm := gsm.NewMockGSM(map[string][]byte{
 		"projects/foo/secrets/bar/versions/latest": []byte("foo_bar_latest"),
@@ -340,7 +362,7 @@ func TestReflectorDefaultLabelOverwriteGSM(t *testing.T) {
 
 func TestReflectorGSMJSONStruct(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	gsm := gsm.NewMockGSM(map[string][]byte{
 		"projects/foo/secrets/bar/versions/latest": []byte(`{"key1": {"int": 1, "string": "hello"}, "key2": {"float": 3.14, "bool": true}}`),
@@ -407,7 +429,7 @@ func TestReflectorGSMJSONStruct(t *testing.T) {
 
 func TestReflectorGSMJSONUnwrap(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	gsm := gsm.NewMockGSM(map[string][]byte{
 		"projects/foo/secrets/bar/versions/latest": []byte(`{"key1": 1, "key2": "val2\nval3"}`),
@@ -461,7 +483,7 @@ func TestReflectorNoReconcile(t *testing.T) {
 	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
 		ctx := context.Background()
 
-		k8sClient := k8sfake.NewSimpleClientset()
+		k8sClient := k8sfake.NewClientset()
 		vaultClient := vault.NewMock(map[string]vault.EngineType{
 			"secrets": engineType,
 		})
@@ -545,7 +567,7 @@ func TestReflectorWithReconcile(t *testing.T) {
 	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
 		ctx := context.Background()
 
-		k8sClient := k8sfake.NewSimpleClientset()
+		k8sClient := k8sfake.NewClientset()
 		vaultClient := vault.NewMock(map[string]vault.EngineType{
 			"secrets": engineType,
 		})
@@ -653,7 +675,7 @@ func TestReflectorWithReconcile(t *testing.T) {
 
 func TestUnsupportedEngineType(t *testing.T) {
 	ctx := context.Background()
-	k8sClient := k8sfake.NewSimpleClientset()
+	k8sClient := k8sfake.NewClientset()
 
 	vaultClient := vault.NewMock(map[string]vault.EngineType{
 		"secrets": vault.EngineTypeKeyValueV2,
@@ -683,3 +705,1152 @@ func TestUnsupportedEngineType(t *testing.T) {
 		t.Fatal("expected error from unsupported engine type")
 	}
 }
+
+func TestReflectorAutoEngineType(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"kv1": vault.EngineTypeKeyValueV1,
+		"kv2": vault.EngineTypeKeyValueV2,
+	})
+	vaultClient.Write("kv1/foo", map[string]any{"foo": "v1"})
+	vaultClient.Write("kv2/data/foo", map[string]any{"foo": "v2"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "kv1/foo",
+			SecretName:      "v1",
+			VaultEngineType: vault.EngineTypeAuto,
+		},
+		{
+			// the logical path, without data/
+			SourceType:      VaultSourceType,
+			Path:            "kv2/foo",
+			SecretName:      "v2",
+			VaultEngineType: vault.EngineTypeAuto,
+		},
+		{
+			// the API path still works
+			SourceType:      VaultSourceType,
+			Path:            "kv2/data/foo",
+			SecretName:      "v2-api",
+			VaultEngineType: vault.EngineTypeAuto,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	for name, expected := range map[string]string{
+		"v1":     "v1",
+		"v2":     "v2",
+		"v2-api": "v2",
+	} {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret %s should be there: %s", name, err)
+		}
+		if string(secret.Data["foo"]) != expected {
+			t.Fatalf("secret %s should contain %q, got %q", name, expected, secret.Data["foo"])
+		}
+	}
+
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "unmounted/foo",
+			SecretName:      "unmounted",
+			VaultEngineType: vault.EngineTypeAuto,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a path without a mount")
+	}
+}
+
+func TestReflectorVaultVersion(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV2,
+	})
+	vaultClient.Write("secrets/data/foo", map[string]any{"foo": "v1"})
+	vaultClient.Write("secrets/data/foo", map[string]any{"foo": "v2"})
+	vaultClient.Write("secrets/data/foo", map[string]any{"foo": "v3"})
+	vaultClient.Write("secrets/metadata/foo", map[string]any{
+		"custom_metadata": map[string]any{"owner": "core-services"},
+	})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/data/foo",
+			SecretName:      "latest",
+			VaultEngineType: vault.EngineTypeKeyValueV2,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/data/foo",
+			SecretName:      "pinned",
+			VaultEngineType: vault.EngineTypeKeyValueV2,
+			VaultVersion:    2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	for name, expected := range map[string]string{
+		"latest": "3",
+		"pinned": "2",
+	} {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret %s should be there: %s", name, err)
+		}
+		if string(secret.Data["foo"]) != "v"+expected {
+			t.Fatalf("secret %s should contain version %s, got %q", name, expected, secret.Data["foo"])
+		}
+		if secret.Annotations[AnnotationVaultVersion] != expected {
+			t.Fatalf("secret %s should be annotated with version %s, got %q",
+				name, expected, secret.Annotations[AnnotationVaultVersion])
+		}
+		if secret.Annotations[AnnotationVaultCreatedTime] == "" {
+			t.Fatalf("secret %s should be annotated with its creation time", name)
+		}
+		if secret.Annotations[AnnotationVaultCustomMetadata] != `{"owner":"core-services"}` {
+			t.Fatalf("secret %s has unexpected custom metadata annotation: %q",
+				name, secret.Annotations[AnnotationVaultCustomMetadata])
+		}
+	}
+
+	vaultClient.DeleteVersion("secrets/data/foo", 2)
+	vaultClient.DestroyVersion("secrets/data/foo", 1)
+	for version, expected := range map[int]string{
+		1: "version 1 of secret secrets/data/foo has been destroyed",
+		2: "version 2 of secret secrets/data/foo was deleted at ",
+		4: "version 4 of secret secrets/data/foo not found",
+	} {
+		err := r.Reflect(ctx, []Mapping{
+			{
+				SourceType:      VaultSourceType,
+				Path:            "secrets/data/foo",
+				SecretName:      "pinned",
+				VaultEngineType: vault.EngineTypeKeyValueV2,
+				VaultVersion:    version,
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected an error containing %q for version %d, got %v", expected, version, err)
+		}
+	}
+
+	// versions aren't supported by key/value v1, even when auto-detected
+	kv1 := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	kv1.Write("secrets/foo", map[string]any{"foo": "bar"})
+	r = NewReflector(
+		kv1,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "kv1",
+			VaultEngineType: vault.EngineTypeAuto,
+			VaultVersion:    1,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error pinning the version of a key/value v1 secret")
+	}
+
+	// a version can't be read with a client that can't pass parameters, but
+	// the latest version still can
+	r = NewReflector(
+		struct{ vault.Logical }{vaultClient},
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithContinueOnError(),
+	)
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/data/foo",
+			SecretName:      "pinned",
+			VaultEngineType: vault.EngineTypeKeyValueV2,
+			VaultVersion:    3,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/data/foo",
+			SecretName:      "latest",
+			VaultEngineType: vault.EngineTypeKeyValueV2,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support reading versions") {
+		t.Fatalf("expected an error reading a version without support, got %v", err)
+	}
+	if len(results) != 2 || results[0].Action != ActionFailed || results[1].Err != nil {
+		t.Fatalf("only the pinned mapping should have failed: %+v", results)
+	}
+}
+
+func TestReflectorVaultNamespace(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "root"})
+	vaultClient.WithNamespace("team-a").Write("secrets/foo", map[string]any{"foo": "team-a"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "root",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "team-a",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			VaultNamespace:  "team-a",
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	for name, expected := range map[string]string{
+		"root":   "root",
+		"team-a": "team-a",
+	} {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret %s should be there: %s", name, err)
+		}
+		if string(secret.Data["foo"]) != expected {
+			t.Fatalf("secret %s should contain %q, got %q", name, expected, secret.Data["foo"])
+		}
+	}
+
+	// a namespace can't be used with a client that doesn't support them
+	r = NewReflector(
+		struct{ vault.Logical }{vaultClient},
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "team-a",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			VaultNamespace:  "team-a",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error using a namespace without namespace support")
+	}
+}
+
+func TestCastData(t *testing.T) {
+	r := &Reflector{}
+	data := map[string]any{
+		"string":  "foo",
+		"bytes":   []byte("bar"),
+		"port":    json.Number("5432"),
+		"ratio":   json.Number("0.5"),
+		"int":     42,
+		"float":   1.5,
+		"enabled": true,
+		"null":    nil,
+		"db": map[string]any{
+			"host":  "db.internal",
+			"port":  json.Number("5432"),
+			"flags": []any{"a", "<b>"},
+		},
+		"hosts": []any{"a", "b"},
+		"empty": map[string]any{},
+	}
+
+	for nestedValues, expected := range map[string]map[string]string{
+		NestedValuesJSON: {
+			"string":  "foo",
+			"bytes":   "bar",
+			"port":    "5432",
+			"ratio":   "0.5",
+			"int":     "42",
+			"float":   "1.5",
+			"enabled": "true",
+			"null":    "null",
+			"db":      `{"flags":["a","<b>"],"host":"db.internal","port":5432}`,
+			"hosts":   `["a","b"]`,
+			"empty":   "{}",
+		},
+		NestedValuesFlatten: {
+			"string":     "foo",
+			"bytes":      "bar",
+			"port":       "5432",
+			"ratio":      "0.5",
+			"int":        "42",
+			"float":      "1.5",
+			"enabled":    "true",
+			"null":       "null",
+			"db.host":    "db.internal",
+			"db.port":    "5432",
+			"db.flags.0": "a",
+			"db.flags.1": "<b>",
+			"hosts.0":    "a",
+			"hosts.1":    "b",
+			"empty":      "{}",
+		},
+	} {
+		casted, err := r.castData(data, nestedValues)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", nestedValues, err)
+		}
+		got := map[string]string{}
+		for k, v := range casted {
+			got[k] = string(v)
+		}
+		if !maps.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", nestedValues, expected, got)
+		}
+	}
+
+	// flattening can't silently overwrite keys
+	_, err := r.castData(map[string]any{
+		"db.port": "1",
+		"db":      map[string]any{"port": "2"},
+	}, NestedValuesFlatten)
+	if err == nil {
+		t.Fatal("expected an error for a key collision while flattening")
+	}
+
+	_, err = r.castData(map[string]any{"secret": struct{}{}}, NestedValuesJSON)
+	if err == nil || !strings.Contains(err.Error(), `unknown type of secret struct {} for key "secret"`) {
+		t.Fatalf("expected an unknown type error, got %v", err)
+	}
+}
+
+func TestReflectorVaultNonStringValues(t *testing.T) {
+	allEngineTest(t, func(t testing.TB, engineType vault.EngineType) {
+		ctx := context.Background()
+		k8sClient := k8sfake.NewClientset()
+
+		vaultClient := vault.NewMock(map[string]vault.EngineType{
+			"secrets": engineType,
+		})
+		mount := vault.Mount{Path: "secrets/", EngineType: engineType}
+		vaultClient.Write(mount.DataPath("secrets/db"), map[string]any{
+			"port":    json.Number("5432"),
+			"tls":     false,
+			"options": map[string]any{"sslmode": "require"},
+		})
+
+		r := NewReflector(
+			vaultClient,
+			gsm.NewMockGSM(nil),
+			k8sClient, DefaultNamespace,
+			DefaultLabelValue,
+		)
+		err := r.Reflect(ctx, []Mapping{
+			{
+				SourceType:        VaultSourceType,
+				Path:              mount.DataPath("secrets/db"),
+				SecretName:        "db",
+				VaultEngineType:   engineType,
+				VaultNestedValues: NestedValuesFlatten,
+			},
+		})
+		if err != nil {
+			t.Fatalf("reflect didn't work: %s", err)
+		}
+
+		secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "db", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret should be there: %s", err)
+		}
+		got := map[string]string{}
+		for k, v := range secret.Data {
+			got[k] = string(v)
+		}
+		expected := map[string]string{
+			"port":            "5432",
+			"tls":             "false",
+			"options.sslmode": "require",
+		}
+		if !maps.Equal(got, expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+}
+
+func TestReflectorMetrics(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "bar"})
+
+	reg := prometheus.NewRegistry()
+	m, err := metrics.New(reg)
+	if err != nil {
+		t.Fatalf("unable to create metrics: %s", err)
+	}
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithMetrics(m),
+	)
+
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "foo",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/missing",
+			SecretName:      "missing",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error reflecting missing secret")
+	}
+
+	expected := `
+# HELP pentagon_errors_total Number of errors encountered while reflecting secrets, by phase.
+# TYPE pentagon_errors_total counter
+pentagon_errors_total{cluster="",namespace="",phase="fetch",secret_name="missing"} 1
+# HELP pentagon_syncs_total Number of successful syncs of a source secret into a kubernetes secret.
+# TYPE pentagon_syncs_total counter
+pentagon_syncs_total{cluster="",namespace="default",secret_name="foo",source_type="vault"} 1
+`
+	if err := testutil.GatherAndCompare(
+		reg,
+		strings.NewReader(expected),
+		"pentagon_errors_total",
+		"pentagon_syncs_total",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReflectorContinueOnError(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo1", map[string]any{"foo": "bar"})
+	vaultClient.Write("secrets/foo2", map[string]any{"foo": "bar"})
+
+	// secrets left over from a previous run: "broken" belongs to a mapping
+	// that will fail, and "stale" is no longer in the mappings at all.
+	for _, name := range []string{"broken", "stale"} {
+		_, err := secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{LabelKey: "test"},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("unable to create %s secret: %s", name, err)
+		}
+	}
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+		WithContinueOnError(),
+	)
+
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo1",
+			SecretName:      "foo1",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/missing",
+			SecretName:      "broken",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo2",
+			SecretName:      "foo2",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error from the missing secret")
+	}
+	if !strings.Contains(err.Error(), "secrets/missing") {
+		t.Errorf("error should mention the failed path: %s", err)
+	}
+
+	expected := []MappingResult{
+		{SourceType: VaultSourceType, SourcePath: "secrets/foo1", Namespace: DefaultNamespace, SecretName: "foo1", Action: ActionCreated},
+		{SourceType: VaultSourceType, SourcePath: "secrets/missing", Namespace: DefaultNamespace, SecretName: "broken", Action: ActionFailed},
+		{SourceType: VaultSourceType, SourcePath: "secrets/foo2", Namespace: DefaultNamespace, SecretName: "foo2", Action: ActionCreated},
+		{Namespace: DefaultNamespace, SecretName: "stale", Action: ActionDeleted},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d: %+v", len(expected), len(results), results)
+	}
+	for i, want := range expected {
+		got := results[i]
+		if (got.Err != nil) != (want.Action == ActionFailed) {
+			t.Errorf("result %d has unexpected error: %v", i, got.Err)
+		}
+		got.Err = nil
+		if got != want {
+			t.Errorf("result %d: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	// the secret whose mapping failed must not have been reconciled away
+	if _, err := secrets.Get(ctx, "broken", metav1.GetOptions{}); err != nil {
+		t.Fatalf("broken should still be there: %s", err)
+	}
+
+	if _, err := secrets.Get(ctx, "foo2", metav1.GetOptions{}); err != nil {
+		t.Fatalf("foo2 should have been created after the failure: %s", err)
+	}
+
+	if _, err := secrets.Get(ctx, "stale", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("stale should have been reconciled: %s", err)
+	}
+}
+
+func TestReflectorStopsOnError(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "bar"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/missing",
+			SecretName:      "missing",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "foo",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error from the missing secret")
+	}
+
+	if len(results) != 1 || results[0].Action != ActionFailed {
+		t.Fatalf("expected a single failed result: %+v", results)
+	}
+
+	_, err = k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "foo", metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("foo should not have been reflected after the failure: %s", err)
+	}
+}
+
+func TestReflectorDryRun(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/new", map[string]any{"foo": "bar"})
+	vaultClient.Write("secrets/existing", map[string]any{"foo": "changed", "added": "x"})
+	vaultClient.Write("secrets/same", map[string]any{"foo": "bar"})
+
+	for name, data := range map[string]map[string][]byte{
+		"existing": {"foo": []byte("bar"), "removed": []byte("x")},
+		"same":     {"foo": []byte("bar")},
+		"stale":    {"foo": []byte("bar")},
+	} {
+		_, err := secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{LabelKey: "test"},
+			},
+			Data: data,
+			Type: v1.SecretTypeOpaque,
+		}, metav1.CreateOptions{FieldManager: FieldManager})
+		if err != nil {
+			t.Fatalf("unable to create %s secret: %s", name, err)
+		}
+	}
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		"test",
+		WithDryRun(false),
+	)
+
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/new",
+			SecretName:      "new",
+			SecretType:      v1.SecretTypeOpaque,
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/existing",
+			SecretName:      "existing",
+			SecretType:      v1.SecretTypeOpaque,
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/same",
+			SecretName:      "same",
+			SecretType:      v1.SecretTypeOpaque,
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("dry run didn't work: %s", err)
+	}
+
+	expectedActions := map[string]Action{
+		"new":      ActionCreated,
+		"existing": ActionUpdated,
+		"same":     ActionUnchanged,
+		"stale":    ActionDeleted,
+	}
+	if len(results) != len(expectedActions) {
+		t.Fatalf("expected %d results, got %+v", len(expectedActions), results)
+	}
+	for _, result := range results {
+		if !result.DryRun {
+			t.Errorf("result for %s should be marked as a dry run", result.SecretName)
+		}
+		if result.Action != expectedActions[result.SecretName] {
+			t.Errorf("unexpected action for %s: %s", result.SecretName, result.Action)
+		}
+		if result.SecretName == "existing" {
+			d := result.Diff
+			if !slices.Equal(d.Added, []string{"added"}) ||
+				!slices.Equal(d.Removed, []string{"removed"}) ||
+				!slices.Equal(d.Changed, []string{"foo"}) {
+				t.Errorf("unexpected diff for existing: %+v", d)
+			}
+		}
+	}
+
+	// nothing should have actually changed
+	if _, err := secrets.Get(ctx, "new", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("new should not have been created: %s", err)
+	}
+	if _, err := secrets.Get(ctx, "stale", metav1.GetOptions{}); err != nil {
+		t.Errorf("stale should not have been deleted: %s", err)
+	}
+	existing, err := secrets.Get(ctx, "existing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("existing should still be there: %s", err)
+	}
+	if string(existing.Data["foo"]) != "bar" {
+		t.Errorf("existing should not have been updated: %s", existing.Data["foo"])
+	}
+}
+
+func TestReflectorServerDryRun(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	// the fake clientset doesn't implement dry-run, so intercept the apply
+	// and make sure it was sent as one.
+	var dryRunApplies int
+	k8sClient.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			t.Errorf("patch was not an apply: %s", patch.GetPatchType())
+		}
+		if !slices.Equal(patch.PatchOptions.DryRun, []string{metav1.DryRunAll}) {
+			t.Errorf("apply was not a dry run: %+v", patch.PatchOptions)
+		}
+		dryRunApplies++
+		return true, &v1.Secret{}, nil
+	})
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "bar"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithDryRun(true),
+	)
+
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/foo",
+			SecretName:      "foo",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("dry run didn't work: %s", err)
+	}
+
+	if dryRunApplies != 1 {
+		t.Fatalf("expected 1 dry-run apply, got %d", dryRunApplies)
+	}
+	if len(results) != 1 || results[0].Action != ActionCreated || !results[0].DryRun {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestReflectorSkipsNoopUpdates(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "bar"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	mappings := []Mapping{
+		{
+			SourceType:             VaultSourceType,
+			Path:                   "secrets/foo",
+			SecretName:             "foo",
+			SecretType:             v1.SecretTypeOpaque,
+			VaultEngineType:        vault.EngineTypeKeyValueV1,
+			AdditionalSecretLabels: map[string]string{"team": "core"},
+		},
+	}
+
+	// creates and updates are both applies
+	countApplies := func() int {
+		n := 0
+		for _, action := range k8sClient.Actions() {
+			if action.GetVerb() == "patch" {
+				n++
+			}
+		}
+		return n
+	}
+
+	for i, expected := range []Action{ActionCreated, ActionUnchanged, ActionUnchanged} {
+		results, err := r.ReflectResults(ctx, mappings)
+		if err != nil {
+			t.Fatalf("reflect %d didn't work: %s", i, err)
+		}
+		if results[0].Action != expected {
+			t.Fatalf("reflect %d: expected %s, got %s", i, expected, results[0].Action)
+		}
+	}
+	if n := countApplies(); n != 1 {
+		t.Fatalf("expected no updates for unchanged secrets, got %d applies", n)
+	}
+
+	// changing the labels should cause an update
+	mappings[0].AdditionalSecretLabels["team"] = "sre"
+	results, err := r.ReflectResults(ctx, mappings)
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUpdated {
+		t.Fatalf("expected an update after changing labels, got %s", results[0].Action)
+	}
+
+	// as should changing the data
+	vaultClient.Write("secrets/foo", map[string]any{"foo": "baz"})
+	results, err = r.ReflectResults(ctx, mappings)
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	if results[0].Action != ActionUpdated {
+		t.Fatalf("expected an update after changing data, got %s", results[0].Action)
+	}
+	if !slices.Equal(results[0].Diff.Changed, []string{"foo"}) {
+		t.Fatalf("unexpected diff: %+v", results[0].Diff)
+	}
+
+	if n := countApplies(); n != 3 {
+		t.Fatalf("expected 1 create and 2 updates, got %d applies", n)
+	}
+}
+
+func TestReflectorAWS(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	awsClient := awssm.NewMockSecretsManager(map[string][]awssm.MockSecretVersion{
+		"foo": {
+			{
+				VersionID:     "v1",
+				VersionStages: []string{"AWSPREVIOUS"},
+				SecretString:  aws.String("foo_v1"),
+			},
+			{
+				VersionID:     "v2",
+				VersionStages: []string{"AWSCURRENT"},
+				SecretString:  aws.String("foo_v2"),
+			},
+		},
+		"arn:aws:secretsmanager:us-east-1:123456789012:secret:bin-AbCdEf": {
+			{
+				VersionID:     "v1",
+				VersionStages: []string{"AWSCURRENT"},
+				SecretBinary:  []byte{0xde, 0xad, 0xbe, 0xef},
+			},
+		},
+	})
+
+	r := NewReflector(
+		nil,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithAWSSecretsManager(awsClient),
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:        AWSSourceType,
+			Path:              "foo",
+			SecretName:        "current",
+			AWSSecretKeyValue: "foo-key",
+		},
+		{
+			SourceType:      AWSSourceType,
+			Path:            "foo",
+			SecretName:      "previous",
+			AWSVersionStage: "AWSPREVIOUS",
+		},
+		{
+			SourceType:   AWSSourceType,
+			Path:         "foo",
+			SecretName:   "pinned",
+			AWSVersionID: "v1",
+		},
+		{
+			SourceType: AWSSourceType,
+			Path:       "arn:aws:secretsmanager:us-east-1:123456789012:secret:bin-AbCdEf",
+			SecretName: "bin",
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+	for name, expected := range map[string]map[string]string{
+		"current":  {"foo-key": "foo_v2"},
+		"previous": {"previous": "foo_v1"},
+		"pinned":   {"pinned": "foo_v1"},
+		"bin":      {"bin": "\xde\xad\xbe\xef"},
+	} {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("secret %s should be there: %s", name, err)
+		}
+		if len(secret.Data) != len(expected) {
+			t.Errorf("secret %s has unexpected keys: %v", name, secret.Data)
+		}
+		for k, v := range expected {
+			if string(secret.Data[k]) != v {
+				t.Errorf("secret %s key %s should be %q, is %q", name, k, v, secret.Data[k])
+			}
+		}
+	}
+}
+
+func TestReflectorAWSJSONUnwrap(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	awsClient := awssm.NewMockSecretsManager(map[string][]awssm.MockSecretVersion{
+		"foo": {
+			{
+				VersionID:     "v1",
+				VersionStages: []string{"AWSCURRENT"},
+				SecretString:  aws.String(`{"username": "admin", "port": 5432}`),
+			},
+		},
+	})
+
+	r := NewReflector(
+		nil,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithAWSSecretsManager(awsClient),
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      AWSSourceType,
+			Path:            "foo",
+			SecretName:      "foo",
+			AWSEncodingType: AWSEncodingTypeJSON,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+
+	if string(secret.Data["username"]) != "admin" {
+		t.Fatalf("secret value does not equal admin: %s", secret.Data["username"])
+	}
+
+	if string(secret.Data["port"]) != "5432" {
+		t.Fatalf("secret value does not equal bare int: %s", secret.Data["port"])
+	}
+}
+
+func TestReflectorAWSNotFound(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	r := NewReflector(
+		nil,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithAWSSecretsManager(awssm.NewMockSecretsManager(nil)),
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType: AWSSourceType,
+			Path:       "missing",
+			SecretName: "missing",
+		},
+	})
+	var notFound *awstypes.ResourceNotFoundException
+	if !stderrors.As(err, &notFound) {
+		t.Fatalf("expected a not found error: %v", err)
+	}
+}
+
+func TestReflectorAzure(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	const vaultURL = "https://foo.vault.azure.net/"
+	azureClient := azurekv.NewMockAzureKV(map[string]*azurekv.Secret{
+		azurekv.SecretID(vaultURL, "bar", ""):   {Value: "bar_latest"},
+		azurekv.SecretID(vaultURL, "bar", "v1"): {Value: "bar_v1"},
+	})
+
+	r := NewReflector(
+		nil,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithAzureKeyVault(azureClient),
+	)
+
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:          AzureSourceType,
+			Path:                "bar",
+			SecretName:          "latest",
+			AzureVaultURL:       vaultURL,
+			AzureObjectType:     AzureObjectTypeSecret,
+			AzureSecretKeyValue: "bar-key",
+		},
+		{
+			SourceType:      AzureSourceType,
+			Path:            "bar",
+			SecretName:      "pinned",
+			AzureVaultURL:   vaultURL,
+			AzureVersion:    "v1",
+			AzureObjectType: AzureObjectTypeSecret,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+
+	secret, err := secrets.Get(ctx, "latest", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("latest should be there: %s", err)
+	}
+	if string(secret.Data["bar-key"]) != "bar_latest" {
+		t.Fatalf("secret value does not equal bar_latest: %s", secret.Data["bar-key"])
+	}
+
+	secret, err = secrets.Get(ctx, "pinned", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("pinned should be there: %s", err)
+	}
+	if string(secret.Data["pinned"]) != "bar_v1" {
+		t.Fatalf("secret value does not equal bar_v1: %s", secret.Data["pinned"])
+	}
+}
+
+func TestReflectorAzureCertificate(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	// a self-signed certificate stored the way Key Vault stores PEM
+	// certificates: the private key followed by the certificate.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pentagon.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %s", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	const vaultURL = "https://foo.vault.azure.net/"
+	azureClient := azurekv.NewMockAzureKV(map[string]*azurekv.Secret{
+		azurekv.SecretID(vaultURL, "cert", ""): {
+			Value:       string(keyPEM) + string(certPEM),
+			ContentType: azurekv.ContentTypePEM,
+		},
+	})
+
+	r := NewReflector(
+		nil,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithAzureKeyVault(azureClient),
+	)
+
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      AzureSourceType,
+			Path:            "cert",
+			SecretName:      "cert",
+			SecretType:      v1.SecretTypeTLS,
+			AzureVaultURL:   vaultURL,
+			AzureObjectType: AzureObjectTypeCertificate,
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "cert", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+
+	if secret.Type != v1.SecretTypeTLS {
+		t.Fatalf("secret should be a TLS secret, is %s", secret.Type)
+	}
+	if !bytes.Equal(secret.Data[v1.TLSCertKey], certPEM) {
+		t.Fatalf("unexpected certificate: %s", secret.Data[v1.TLSCertKey])
+	}
+	if !bytes.Equal(secret.Data[v1.TLSPrivateKeyKey], keyPEM) {
+		t.Fatalf("unexpected key: %s", secret.Data[v1.TLSPrivateKeyKey])
+	}
+}
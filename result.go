@@ -0,0 +1,114 @@
+package pentagon
+
+import "encoding/json"
+
+// Action describes what pentagon did with a kubernetes secret.
+type Action string
+
+const (
+	// ActionCreated means that the kubernetes secret didn't exist and was
+	// created.
+	ActionCreated Action = "created"
+
+	// ActionUpdated means that the existing kubernetes secret was updated.
+	ActionUpdated Action = "updated"
+
+	// ActionUnchanged means that the existing kubernetes secret already
+	// matched its source.
+	ActionUnchanged Action = "unchanged"
+
+	// ActionDeleted means that the kubernetes secret was deleted during
+	// reconciliation because it's no longer in the mappings.
+	ActionDeleted Action = "deleted"
+
+	// ActionAdopted means that a kubernetes secret that pentagon didn't
+	// manage already existed, and pentagon took ownership of it.
+	ActionAdopted Action = "adopted"
+
+	// ActionSkipped means that a kubernetes secret that pentagon doesn't
+	// manage already existed, and was left alone.
+	ActionSkipped Action = "skipped"
+
+	// ActionFailed means that an error prevented the kubernetes secret from
+	// being written (or deleted).
+	ActionFailed Action = "failed"
+)
+
+// sourceTypeNames are the human-readable names of each source type, used
+// for logging.
+var sourceTypeNames = map[string]string{
+	VaultSourceType: "vault",
+	GSMSourceType:   "GSM",
+	AWSSourceType:   "AWS",
+	AzureSourceType: "Azure",
+}
+
+// MappingResult is the outcome of reflecting a single mapping, or of deleting
+// a secret during reconciliation (in which case SourceType and SourcePath are
+// empty).
+type MappingResult struct {
+	// SourceType is the source type of the mapping.
+	SourceType string
+
+	// SourcePath is the path of the secret in its source.
+	SourcePath string
+
+	// Cluster is the name of the kubernetes cluster of the secret.  Results
+	// for clusters that couldn't be reached only have a Cluster.
+	Cluster string
+
+	// Namespace is the namespace of the kubernetes secret.
+	Namespace string
+
+	// SecretName is the name of the kubernetes secret.
+	SecretName string
+
+	// Action is what was done to the kubernetes secret.  When DryRun is set,
+	// it's what would have been done.
+	Action Action
+
+	// DryRun is true if the Action was only planned and not applied.
+	DryRun bool
+
+	// Diff describes how the kubernetes secret differs from its desired
+	// state.  It's nil unless the secret already existed.
+	Diff *Diff
+
+	// Warning describes a problem that didn't fail the mapping, like its
+	// kubernetes secret being skipped.
+	Warning string
+
+	// Err is the error that caused this mapping to fail, if any.
+	Err error
+}
+
+// MarshalJSON implements json.Marshaler, rendering Err as a string.
+func (m MappingResult) MarshalJSON() ([]byte, error) {
+	var errString string
+	if m.Err != nil {
+		errString = m.Err.Error()
+	}
+	return json.Marshal(struct {
+		SourceType string `json:"sourceType,omitempty"`
+		SourcePath string `json:"sourcePath,omitempty"`
+		Cluster    string `json:"cluster,omitempty"`
+		Namespace  string `json:"namespace,omitempty"`
+		SecretName string `json:"secretName"`
+		Action     Action `json:"action"`
+		DryRun     bool   `json:"dryRun"`
+		Diff       *Diff  `json:"diff,omitempty"`
+		Warning    string `json:"warning,omitempty"`
+		Error      string `json:"error,omitempty"`
+	}{
+		SourceType: m.SourceType,
+		SourcePath: m.SourcePath,
+		Cluster:    m.Cluster,
+		Namespace:  m.Namespace,
+		SecretName: m.SecretName,
+		Action:     m.Action,
+		DryRun:     m.DryRun,
+		Diff:       m.Diff,
+		Warning:    m.Warning,
+		Error:      errString,
+	})
+}
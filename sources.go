@@ -0,0 +1,90 @@
+package pentagon
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// fetchSources merges the data of each of mapping's sources, in order,
+// resolving keys that more than one source has with the mapping's
+// KeyCollisionPolicy.  The annotations describing individual sources (like
+// vault versions) are left out, since they'd be ambiguous.
+func (r *Reflector) fetchSources(ctx context.Context, mapping Mapping) (map[string][]byte, map[string]string, error) {
+	merged := map[string][]byte{}
+	owners := map[string]Mapping{}
+	for _, source := range mapping.Sources {
+		// sources that store a single value use the mapping's secret name as
+		// their default key, like they do on their own.
+		source.SecretName = mapping.SecretName
+
+		data, _, err := r.fetchMapping(ctx, source)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, k := range slices.Sorted(maps.Keys(data)) {
+			if owner, ok := owners[k]; ok {
+				switch mapping.KeyCollisionPolicy {
+				case KeyCollisionFirstWins:
+					continue
+				case KeyCollisionLastWins:
+				default:
+					return nil, nil, fmt.Errorf(
+						"key %q is in both %s and %s",
+						k,
+						describeSource(owner),
+						describeSource(source),
+					)
+				}
+			}
+			merged[k] = data[k]
+			owners[k] = source
+		}
+	}
+	return merged, nil, nil
+}
+
+// mappingSourceType returns the source type of mapping.  For mappings with
+// sources, that's the source types of all of them, separated by commas.
+func mappingSourceType(mapping Mapping) string {
+	if len(mapping.Sources) == 0 {
+		return mapping.SourceType
+	}
+
+	types := map[string]struct{}{}
+	for _, source := range mapping.Sources {
+		types[source.SourceType] = struct{}{}
+	}
+	return strings.Join(slices.Sorted(maps.Keys(types)), ",")
+}
+
+// mappingSourcePath returns the path of mapping's secret.  For mappings with
+// sources, that's the paths of all of them, separated by commas.
+func mappingSourcePath(mapping Mapping) string {
+	if len(mapping.Sources) == 0 {
+		return mapping.Path
+	}
+
+	paths := make([]string, 0, len(mapping.Sources))
+	for _, source := range mapping.Sources {
+		paths = append(paths, source.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+// describeSource describes where mapping's secret comes from, for logs and
+// errors.
+func describeSource(mapping Mapping) string {
+	if len(mapping.Sources) == 0 {
+		return fmt.Sprintf("%s secret %s", sourceTypeNames[mapping.SourceType], mapping.Path)
+	}
+
+	descriptions := make([]string, 0, len(mapping.Sources))
+	for _, source := range mapping.Sources {
+		descriptions = append(descriptions, describeSource(source))
+	}
+	return strings.Join(descriptions, ", ")
+}
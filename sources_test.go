@@ -0,0 +1,133 @@
+package pentagon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestReflectorSources(t *testing.T) {
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/db", map[string]any{
+		"DB_PASSWORD": "hunter2",
+		"API_KEY":     "from-vault",
+	})
+	gsmClient := gsm.NewMockGSM(map[string][]byte{
+		"projects/foo/secrets/api-key/versions/latest": []byte("from-gsm"),
+	})
+
+	sources := []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/db",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+		{
+			SourceType:        GSMSourceType,
+			Path:              "projects/foo/secrets/api-key/versions/latest",
+			GSMSecretKeyValue: "API_KEY",
+		},
+	}
+
+	for policy, expected := range map[string]string{
+		KeyCollisionFirstWins: "from-vault",
+		KeyCollisionLastWins:  "from-gsm",
+	} {
+		t.Run(policy, func(t *testing.T) {
+			ctx := context.Background()
+			k8sClient := k8sfake.NewClientset()
+			r := NewReflector(
+				vaultClient,
+				gsmClient,
+				k8sClient, DefaultNamespace,
+				"test",
+			)
+
+			results, err := r.ReflectResults(ctx, []Mapping{
+				{
+					SecretName:         "app",
+					Sources:            sources,
+					KeyCollisionPolicy: policy,
+				},
+			})
+			if err != nil {
+				t.Fatalf("reflect didn't work: %s", err)
+			}
+			if results[0].SourceType != "gsm,vault" ||
+				results[0].SourcePath != "secrets/db,projects/foo/secrets/api-key/versions/latest" {
+				t.Fatalf("unexpected result: %+v", results[0])
+			}
+
+			secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "app", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("secret should be there: %s", err)
+			}
+			if string(secret.Data["DB_PASSWORD"]) != "hunter2" {
+				t.Fatalf("DB_PASSWORD should be hunter2, is %q", secret.Data["DB_PASSWORD"])
+			}
+			if string(secret.Data["API_KEY"]) != expected {
+				t.Fatalf("API_KEY should be %q, is %q", expected, secret.Data["API_KEY"])
+			}
+		})
+	}
+
+	t.Run(KeyCollisionError, func(t *testing.T) {
+		r := NewReflector(
+			vaultClient,
+			gsmClient,
+			k8sfake.NewClientset(), DefaultNamespace,
+			"test",
+		)
+		err := r.Reflect(context.Background(), []Mapping{
+			{
+				SecretName:         "app",
+				Sources:            sources,
+				KeyCollisionPolicy: KeyCollisionError,
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), `key "API_KEY" is in both vault secret secrets/db and GSM secret`) {
+			t.Fatalf("expected a key collision error, got %v", err)
+		}
+	})
+}
+
+func TestReflectorSourcesDefaultKey(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+	r := NewReflector(
+		vault.NewMock(nil),
+		gsm.NewMockGSM(map[string][]byte{
+			"projects/foo/secrets/a/versions/latest": []byte("a"),
+		}),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+
+	// a source without a key of its own uses the mapping's secret name
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SecretName: "app",
+			Sources: []Mapping{
+				{SourceType: GSMSourceType, Path: "projects/foo/secrets/a/versions/latest"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("reflect didn't work: %s", err)
+	}
+	secret, err := k8sClient.CoreV1().Secrets(DefaultNamespace).Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret should be there: %s", err)
+	}
+	if string(secret.Data["app"]) != "a" {
+		t.Fatalf("secret should have key app, has %v", secret.Data)
+	}
+}
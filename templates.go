@@ -0,0 +1,117 @@
+package pentagon
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// templateFuncs are the functions available to value templates.  They're
+// deliberately limited to pure functions over their arguments, so templates
+// can't read the environment or files.
+var templateFuncs = template.FuncMap{
+	// index replaces the built-in, which returns an empty string for a
+	// missing key instead of failing like a missing .key does.
+	"index": func(data map[string]string, key string) (string, error) {
+		v, ok := data[key]
+		if !ok {
+			return "", fmt.Errorf("map has no entry for key %q", key)
+		}
+		return v, nil
+	},
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", errors.New("invalid base64")
+		}
+		return string(decoded), nil
+	},
+	"toJson": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("error encoding JSON: %s", err)
+		}
+		return string(encoded), nil
+	},
+	"toYaml": func(v any) (string, error) {
+		encoded, err := yaml.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("error encoding YAML: %s", err)
+		}
+		return strings.TrimSuffix(string(encoded), "\n"), nil
+	},
+	"default": func(def string, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+	"required": func(msg string, v string) (string, error) {
+		if v == "" {
+			return "", errors.New(msg)
+		}
+		return v, nil
+	},
+	"trim": strings.TrimSpace,
+	"sha256sum": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+}
+
+// parseValueTemplate parses the template of the value of key.
+func parseValueTemplate(key, text string) (*template.Template, error) {
+	return template.New(key).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// validateTemplates checks that templates parse and are written to valid
+// kubernetes secret keys.
+func validateTemplates(templates map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(templates)) {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid template key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if _, err := parseValueTemplate(key, templates[key]); err != nil {
+			return fmt.Errorf("invalid template for key %q: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// renderTemplates renders each of templates over data, and returns the
+// rendered values by key.  Templates are executed with data as a map of
+// strings, and fail if they refer to a key that isn't in it.
+func renderTemplates(templates map[string]string, data map[string][]byte) (map[string][]byte, error) {
+	values := make(map[string]string, len(data))
+	for k, v := range data {
+		values[k] = string(v)
+	}
+
+	rendered := make(map[string][]byte, len(templates))
+	for _, key := range slices.Sorted(maps.Keys(templates)) {
+		tmpl, err := parseValueTemplate(key, templates[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for key %q: %s", key, err)
+		}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, values); err != nil {
+			return nil, fmt.Errorf("error rendering template for key %q: %s", key, err)
+		}
+		rendered[key] = []byte(b.String())
+	}
+	return rendered, nil
+}
@@ -0,0 +1,206 @@
+package vault
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	// DefaultKubernetesMountPath is the default path at which the kubernetes
+	// auth method is mounted.
+	DefaultKubernetesMountPath = "kubernetes"
+
+	// DefaultKubernetesTokenPath is where kubernetes mounts the pod's service
+	// account token.
+	DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// DefaultAppRoleMountPath is the default path at which the approle auth
+	// method is mounted.
+	DefaultAppRoleMountPath = "approle"
+
+	// DefaultGCPMountPath is the default path at which the gcp auth method is
+	// mounted.
+	DefaultGCPMountPath = "gcp"
+)
+
+// Authenticator logs in to vault with an auth method.  Login returns the
+// resulting secret, whose Auth field contains the client token and its lease.
+type Authenticator interface {
+	Login(l Logical) (*api.Secret, error)
+}
+
+// CredentialSource describes where to read a credential from, so that it
+// doesn't need to be inlined in the configuration.  Exactly one of File and
+// Env should be set.
+type CredentialSource struct {
+	// File is the path of a file containing the credential.  Surrounding
+	// whitespace is ignored.
+	File string `yaml:"file"`
+
+	// Env is the name of an environment variable containing the credential.
+	Env string `yaml:"env"`
+}
+
+// Read returns the credential.
+func (c CredentialSource) Read() (string, error) {
+	switch {
+	case c.File != "" && c.Env != "":
+		return "", fmt.Errorf("only one of file and env may be set")
+	case c.File != "":
+		b, err := os.ReadFile(c.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	case c.Env != "":
+		v, ok := os.LookupEnv(c.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", c.Env)
+		}
+		return strings.TrimSpace(v), nil
+	default:
+		return "", fmt.Errorf("one of file and env must be set")
+	}
+}
+
+// KubernetesAuth logs in to vault with the kubernetes auth method, using the
+// pod's service account token.
+type KubernetesAuth struct {
+	// MountPath is the path at which the kubernetes auth method is mounted.
+	// Defaults to DefaultKubernetesMountPath.
+	MountPath string
+
+	// Role is the vault role to log in as.
+	Role string
+
+	// TokenPath is the path to the (projected) service account token.
+	// Defaults to DefaultKubernetesTokenPath.
+	TokenPath string
+}
+
+// Login logs in to vault and returns the resulting secret, whose Auth field
+// contains the client token.
+func (k KubernetesAuth) Login(l Logical) (*api.Secret, error) {
+	if k.Role == "" {
+		return nil, fmt.Errorf("a role is required for kubernetes auth")
+	}
+
+	tokenPath := k.TokenPath
+	if tokenPath == "" {
+		tokenPath = DefaultKubernetesTokenPath
+	}
+	jwt, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %s", err)
+	}
+
+	return login(l, k.MountPath, DefaultKubernetesMountPath, map[string]any{
+		"role": k.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+}
+
+// AppRoleAuth logs in to vault with the approle auth method.
+type AppRoleAuth struct {
+	// MountPath is the path at which the approle auth method is mounted.
+	// Defaults to DefaultAppRoleMountPath.
+	MountPath string
+
+	// RoleID is where to read the role_id from.
+	RoleID CredentialSource
+
+	// SecretID is where to read the secret_id from.
+	SecretID CredentialSource
+}
+
+// Login logs in to vault and returns the resulting secret, whose Auth field
+// contains the client token.
+func (a AppRoleAuth) Login(l Logical) (*api.Secret, error) {
+	roleID, err := a.RoleID.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading approle role_id: %s", err)
+	}
+	secretID, err := a.SecretID.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading approle secret_id: %s", err)
+	}
+
+	return login(l, a.MountPath, DefaultAppRoleMountPath, map[string]any{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+}
+
+// GCPAuth logs in to vault with the gcp auth method, using the identity of
+// the default service account from the GCP metadata service.
+type GCPAuth struct {
+	// MountPath is the path at which the gcp auth method is mounted.
+	// Defaults to DefaultGCPMountPath.
+	MountPath string
+
+	// Role is the vault role to log in as.
+	Role string
+
+	// VaultAddress is the address of the vault server, which is used to
+	// build the audience of the identity token.
+	VaultAddress string
+}
+
+// Login logs in to vault and returns the resulting secret, whose Auth field
+// contains the client token.
+func (g GCPAuth) Login(l Logical) (*api.Secret, error) {
+	vaultAddress, err := url.Parse(g.VaultAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing vault address: %s", err)
+	}
+
+	// just make a request directly to the metadata server rather
+	// than going through the APIs which don't seem to wrap this functionality
+	// in a terribly convenient way.
+	metadataURL := url.URL{
+		Path: "instance/service-accounts/default/identity",
+	}
+	values := url.Values{}
+	values.Add(
+		"audience",
+		fmt.Sprintf("%s/vault/%s", vaultAddress.Hostname(), g.Role),
+	)
+	values.Add("format", "full")
+	metadataURL.RawQuery = values.Encode()
+
+	// `jwt` should be a base64-encoded jwt.
+	jwt, err := metadata.Get(metadataURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving JWT from metadata API: %s", err)
+	}
+
+	return login(l, g.MountPath, DefaultGCPMountPath, map[string]any{
+		"role": g.Role,
+		"jwt":  jwt,
+	})
+}
+
+// login writes data to the login endpoint of the auth method mounted at
+// mountPath (or defaultMountPath if that's empty), and makes sure that the
+// response contains a token.
+func login(l Logical, mountPath, defaultMountPath string, data map[string]any) (*api.Secret, error) {
+	if mountPath == "" {
+		mountPath = defaultMountPath
+	}
+	mountPath = strings.Trim(mountPath, "/")
+
+	secret, err := l.Write(fmt.Sprintf("auth/%s/login", mountPath), data)
+	if err != nil {
+		return nil, fmt.Errorf("error logging in to vault via %s: %s", mountPath, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("no token returned logging in to vault via %s", mountPath)
+	}
+
+	return secret, nil
+}
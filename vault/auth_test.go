@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// fakeVault starts a vault HTTP server that accepts logins at loginPath when
+// the request body matches expected.
+func fakeVault(t testing.TB, loginPath string, expected map[string]string) *api.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut && req.Method != http.MethodPost || req.URL.Path != "/v1/"+loginPath {
+			http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+			return
+		}
+
+		body := map[string]string{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, `{"errors":["bad request"]}`, http.StatusBadRequest)
+			return
+		}
+		for k, v := range expected {
+			if body[k] != v {
+				http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{
+				"client_token":   "s.test-token",
+				"lease_duration": 3600,
+				"renewable":      true,
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	c := api.DefaultConfig()
+	c.Address = srv.URL
+	client, err := api.NewClient(c)
+	if err != nil {
+		t.Fatalf("error creating vault client: %s", err)
+	}
+	client.ClearToken()
+	return client
+}
+
+func TestKubernetesAuth(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("service-account-jwt\n"), 0o600); err != nil {
+		t.Fatalf("error writing token: %s", err)
+	}
+
+	client := fakeVault(t, "auth/k8s-prod/login", map[string]string{
+		"role": "pentagon",
+		"jwt":  "service-account-jwt",
+	})
+
+	secret, err := KubernetesAuth{
+		MountPath: "/k8s-prod/",
+		Role:      "pentagon",
+		TokenPath: tokenPath,
+	}.Login(client.Logical())
+	if err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if secret.Auth.ClientToken != "s.test-token" {
+		t.Fatalf("unexpected token: %s", secret.Auth.ClientToken)
+	}
+
+	// the wrong role should be rejected
+	_, err = KubernetesAuth{
+		MountPath: "k8s-prod",
+		Role:      "other",
+		TokenPath: tokenPath,
+	}.Login(client.Logical())
+	if err == nil {
+		t.Fatal("expected login with the wrong role to fail")
+	}
+}
+
+func TestKubernetesAuthDefaults(t *testing.T) {
+	client := fakeVault(t, "auth/kubernetes/login", nil)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("jwt"), 0o600); err != nil {
+		t.Fatalf("error writing token: %s", err)
+	}
+
+	// the default mount path should be used
+	if _, err := (KubernetesAuth{Role: "pentagon", TokenPath: tokenPath}).Login(client.Logical()); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	if _, err := (KubernetesAuth{TokenPath: tokenPath}).Login(client.Logical()); err == nil {
+		t.Fatal("expected login without a role to fail")
+	}
+}
+
+func TestAppRoleAuth(t *testing.T) {
+	roleIDPath := filepath.Join(t.TempDir(), "role-id")
+	if err := os.WriteFile(roleIDPath, []byte("the-role-id\n"), 0o600); err != nil {
+		t.Fatalf("error writing role id: %s", err)
+	}
+	t.Setenv("PENTAGON_TEST_SECRET_ID", "the-secret-id")
+
+	client := fakeVault(t, "auth/bootstrap/login", map[string]string{
+		"role_id":   "the-role-id",
+		"secret_id": "the-secret-id",
+	})
+
+	secret, err := AppRoleAuth{
+		MountPath: "bootstrap",
+		RoleID:    CredentialSource{File: roleIDPath},
+		SecretID:  CredentialSource{Env: "PENTAGON_TEST_SECRET_ID"},
+	}.Login(client.Logical())
+	if err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if secret.Auth.ClientToken != "s.test-token" {
+		t.Fatalf("unexpected token: %s", secret.Auth.ClientToken)
+	}
+
+	// the default mount path doesn't exist on this server
+	_, err = AppRoleAuth{
+		RoleID:   CredentialSource{File: roleIDPath},
+		SecretID: CredentialSource{Env: "PENTAGON_TEST_SECRET_ID"},
+	}.Login(client.Logical())
+	if err == nil {
+		t.Fatal("expected login at the default mount path to fail")
+	}
+}
+
+func TestCredentialSource(t *testing.T) {
+	t.Setenv("PENTAGON_TEST_CREDENTIAL", "from-env")
+	path := filepath.Join(t.TempDir(), "credential")
+	if err := os.WriteFile(path, []byte("  from-file\n"), 0o600); err != nil {
+		t.Fatalf("error writing credential: %s", err)
+	}
+
+	for name, tbl := range map[string]struct {
+		source   CredentialSource
+		expected string
+		err      bool
+	}{
+		"file":        {source: CredentialSource{File: path}, expected: "from-file"},
+		"env":         {source: CredentialSource{Env: "PENTAGON_TEST_CREDENTIAL"}, expected: "from-env"},
+		"missing-env": {source: CredentialSource{Env: "PENTAGON_TEST_NOT_SET"}, err: true},
+		"missing":     {source: CredentialSource{File: filepath.Join(t.TempDir(), "nope")}, err: true},
+		"empty":       {source: CredentialSource{}, err: true},
+		"both":        {source: CredentialSource{File: path, Env: "PENTAGON_TEST_CREDENTIAL"}, err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v, err := tbl.source.Read()
+			if (err != nil) != tbl.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v != tbl.expected {
+				t.Fatalf("expected %q, got %q", tbl.expected, v)
+			}
+		})
+	}
+}
+
+func TestGCPAuth(t *testing.T) {
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/identity" ||
+			req.URL.Query().Get("audience") != "vault.example.com/vault/pentagon" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Write([]byte("gcp-jwt"))
+	}))
+	defer metadataServer.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadataServer.URL, "http://"))
+
+	client := fakeVault(t, "auth/gcp/login", map[string]string{
+		"role": "pentagon",
+		"jwt":  "gcp-jwt",
+	})
+
+	secret, err := GCPAuth{
+		Role:         "pentagon",
+		VaultAddress: "https://vault.example.com:8200",
+	}.Login(client.Logical())
+	if err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if secret.Auth.ClientToken != "s.test-token" {
+		t.Fatalf("unexpected token: %s", secret.Auth.ClientToken)
+	}
+}
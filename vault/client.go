@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"github.com/hashicorp/vault/api"
+)
+
+// Client is a Logical backed by a vault API client that supports Vault
+// Enterprise namespaces.
+type Client struct {
+	client *api.Client
+}
+
+// NewClient returns a new Client that makes requests with client.
+func NewClient(client *api.Client) *Client {
+	return &Client{
+		client: client,
+	}
+}
+
+// Read reads a secret from vault.
+func (c *Client) Read(path string) (*api.Secret, error) {
+	return c.client.Logical().Read(path)
+}
+
+// ReadWithData reads a secret from vault, passing data as query parameters.
+func (c *Client) ReadWithData(path string, data map[string][]string) (*api.Secret, error) {
+	return c.client.Logical().ReadWithData(path, data)
+}
+
+// List lists the keys under path.
+func (c *Client) List(path string) (*api.Secret, error) {
+	return c.client.Logical().List(path)
+}
+
+// Write writes data to vault.
+func (c *Client) Write(path string, data map[string]any) (*api.Secret, error) {
+	return c.client.Logical().Write(path, data)
+}
+
+// WithNamespace returns a Logical whose requests are made in namespace by
+// setting the X-Vault-Namespace header.
+func (c *Client) WithNamespace(namespace string) Logical {
+	return &namespacedClient{
+		client:    c.client,
+		namespace: namespace,
+	}
+}
+
+// namespacedClient makes requests in a namespace.  The underlying client is
+// cloned for each request, rather than once, so that token changes made by a
+// TokenManager are picked up.
+type namespacedClient struct {
+	client    *api.Client
+	namespace string
+}
+
+// Read reads a secret from vault.
+func (n *namespacedClient) Read(path string) (*api.Secret, error) {
+	return n.client.WithNamespace(n.namespace).Logical().Read(path)
+}
+
+// ReadWithData reads a secret from vault, passing data as query parameters.
+func (n *namespacedClient) ReadWithData(path string, data map[string][]string) (*api.Secret, error) {
+	return n.client.WithNamespace(n.namespace).Logical().ReadWithData(path, data)
+}
+
+// List lists the keys under path.
+func (n *namespacedClient) List(path string) (*api.Secret, error) {
+	return n.client.WithNamespace(n.namespace).Logical().List(path)
+}
+
+// Write writes data to vault.
+func (n *namespacedClient) Write(path string, data map[string]any) (*api.Secret, error) {
+	return n.client.WithNamespace(n.namespace).Logical().Write(path, data)
+}
@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestClientNamespaces(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"namespace": req.Header.Get(api.NamespaceHeaderName),
+				"token":     req.Header.Get(api.AuthHeaderName),
+			},
+		})
+	}))
+	defer srv.Close()
+
+	c := api.DefaultConfig()
+	c.Address = srv.URL
+	apiClient, err := api.NewClient(c)
+	if err != nil {
+		t.Fatalf("error creating vault client: %s", err)
+	}
+	apiClient.ClearNamespace()
+	apiClient.SetToken("first")
+
+	client := NewClient(apiClient)
+	namespaced := client.WithNamespace("team-a")
+
+	// the token may change after the namespaced client was created, for
+	// example when it's renewed by a TokenManager
+	apiClient.SetToken("second")
+
+	s, err := namespaced.Read("secrets/foo")
+	if err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	if s.Data["namespace"] != "team-a" {
+		t.Errorf("expected the request to be in namespace team-a, got %v", s.Data["namespace"])
+	}
+	if s.Data["token"] != "second" {
+		t.Errorf("expected the current token to be used, got %v", s.Data["token"])
+	}
+
+	s, err = client.Read("secrets/foo")
+	if err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	if s.Data["namespace"] != "" {
+		t.Errorf("expected the request to be in the root namespace, got %v", s.Data["namespace"])
+	}
+}
@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ListSecrets returns the paths of the secrets under the logical path prefix
+// in mount, relative to prefix.  Only the secrets directly under prefix are
+// returned unless recursive is set.
+func ListSecrets(l Lister, mount Mount, prefix string, recursive bool) ([]string, error) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+
+	var secrets []string
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+
+		listPath := mount.ListPath(prefix + dir)
+		listed, err := l.List(listPath)
+		if err != nil {
+			return nil, fmt.Errorf("error listing vault path '%s': %s", listPath, err)
+		}
+		// note that vault returns nothing when there's nothing to list
+		if listed == nil {
+			continue
+		}
+
+		keys, ok := listed.Data["keys"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response listing vault path '%s'", listPath)
+		}
+		for _, k := range keys {
+			key, ok := k.(string)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("unexpected key listing vault path '%s': %v", listPath, k)
+			}
+			if strings.HasSuffix(key, "/") {
+				if recursive {
+					dirs = append(dirs, dir+key)
+				}
+				continue
+			}
+			secrets = append(secrets, dir+key)
+		}
+	}
+
+	slices.Sort(secrets)
+	return secrets, nil
+}
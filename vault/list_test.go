@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListSecrets(t *testing.T) {
+	for name, engineType := range map[string]EngineType{
+		"kv-v1": EngineTypeKeyValueV1,
+		"kv-v2": EngineTypeKeyValueV2,
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewMock(map[string]EngineType{"secrets": engineType})
+			mount := Mount{Path: "secrets/", EngineType: engineType}
+			for _, p := range []string{
+				"secrets/apps/foo",
+				"secrets/apps/bar",
+				"secrets/apps/team/baz",
+				"secrets/apps/team/deep/qux",
+				"secrets/other/nope",
+			} {
+				if _, err := m.Write(mount.DataPath(p), map[string]any{"k": "v"}); err != nil {
+					t.Fatalf("error writing %s: %s", p, err)
+				}
+			}
+
+			for _, tbl := range []struct {
+				recursive bool
+				expected  []string
+			}{
+				{recursive: false, expected: []string{"bar", "foo"}},
+				{recursive: true, expected: []string{"bar", "foo", "team/baz", "team/deep/qux"}},
+			} {
+				listed, err := ListSecrets(m, mount, "secrets/apps/", tbl.recursive)
+				if err != nil {
+					t.Fatalf("error listing: %s", err)
+				}
+				if !slices.Equal(listed, tbl.expected) {
+					t.Fatalf("recursive=%t: expected %v, got %v", tbl.recursive, tbl.expected, listed)
+				}
+			}
+
+			listed, err := ListSecrets(m, mount, "secrets/empty", true)
+			if err != nil {
+				t.Fatalf("error listing: %s", err)
+			}
+			if len(listed) != 0 {
+				t.Fatalf("expected nothing under an empty path, got %v", listed)
+			}
+		})
+	}
+}
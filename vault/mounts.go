@@ -0,0 +1,162 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// mountsPath is the path used to look up the mount a path belongs to.  It's
+// used by the vault UI and only requires a policy allowing access to the path
+// itself.
+const mountsPath = "sys/internal/ui/mounts/"
+
+// Mount is a secrets engine mounted in vault.
+type Mount struct {
+	// Path is the path at which the engine is mounted, with a trailing
+	// slash.
+	Path string
+
+	// EngineType is the type of the engine.
+	EngineType EngineType
+}
+
+// APIPath returns the path used to read the secret at the logical path from
+// the mount.  For key/value v2 that's the path with "data/" inserted after
+// the mount path, unless it's already there.
+func (m Mount) APIPath(path string) string {
+	if m.EngineType != EngineTypeKeyValueV2 {
+		return path
+	}
+
+	if strings.HasPrefix(strings.TrimPrefix(path, m.Path), "data/") {
+		return path
+	}
+	return m.DataPath(path)
+}
+
+// DataPath returns the path used to read the secret at the logical path from
+// the mount.  Unlike APIPath, "data/" is always inserted for key/value v2.
+func (m Mount) DataPath(path string) string {
+	return m.insertSegment(path, "data/")
+}
+
+// ListPath returns the path used to list the keys under the logical path in
+// the mount.  For key/value v2 that's the path with "metadata/" inserted
+// after the mount path.
+func (m Mount) ListPath(path string) string {
+	return m.insertSegment(path, "metadata/")
+}
+
+// LogicalPath returns path without the "data/" or "metadata/" segment that
+// key/value v2 API paths have after the mount path.
+func (m Mount) LogicalPath(path string) string {
+	if m.EngineType != EngineTypeKeyValueV2 {
+		return path
+	}
+
+	rest, ok := strings.CutPrefix(path, m.Path)
+	if !ok {
+		return path
+	}
+	for _, segment := range []string{"data", "metadata"} {
+		if rest == segment {
+			return m.Path
+		}
+		if r, ok := strings.CutPrefix(rest, segment+"/"); ok {
+			return m.Path + r
+		}
+	}
+	return path
+}
+
+// insertSegment inserts segment after the mount path for key/value v2.
+func (m Mount) insertSegment(path, segment string) string {
+	if m.EngineType != EngineTypeKeyValueV2 {
+		return path
+	}
+	return m.Path + segment + strings.TrimPrefix(path, m.Path)
+}
+
+// contains returns true if path is within the mount.
+func (m Mount) contains(path string) bool {
+	return strings.HasPrefix(path, m.Path)
+}
+
+// ResolveMount looks up the mount that path belongs to.  Only key/value
+// mounts are supported.
+func ResolveMount(l Logical, path string) (Mount, error) {
+	path = strings.TrimPrefix(path, "/")
+	secret, err := l.Read(mountsPath + path)
+	if err != nil {
+		return Mount{}, fmt.Errorf("error looking up mount for %s: %s", path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return Mount{}, fmt.Errorf("no mount found for %s", path)
+	}
+
+	mountPath, _ := secret.Data["path"].(string)
+	if mountPath == "" {
+		return Mount{}, fmt.Errorf("no mount found for %s", path)
+	}
+	if !strings.HasSuffix(mountPath, "/") {
+		mountPath += "/"
+	}
+
+	engine, _ := secret.Data["type"].(string)
+	if engine != "kv" && engine != "generic" {
+		return Mount{}, fmt.Errorf(
+			"unsupported secrets engine %q mounted at %s",
+			engine,
+			mountPath,
+		)
+	}
+
+	mount := Mount{
+		Path:       mountPath,
+		EngineType: EngineTypeKeyValueV1,
+	}
+	if options, ok := secret.Data["options"].(map[string]any); ok {
+		if fmt.Sprint(options["version"]) == "2" {
+			mount.EngineType = EngineTypeKeyValueV2
+		}
+	}
+	return mount, nil
+}
+
+// MountCache caches the mounts that paths belong to, so that they only need
+// to be looked up once per mount.  It's safe for concurrent use.
+type MountCache struct {
+	mu     sync.Mutex
+	mounts map[string][]Mount // by namespace
+}
+
+// NewMountCache returns a new, empty MountCache.
+func NewMountCache() *MountCache {
+	return &MountCache{
+		mounts: map[string][]Mount{},
+	}
+}
+
+// Resolve returns the mount that path belongs to in namespace, looking it up
+// with l (which should make requests in namespace) if it isn't cached.
+func (c *MountCache) Resolve(l Logical, namespace, path string) (Mount, error) {
+	path = strings.TrimPrefix(path, "/")
+	namespace = strings.Trim(namespace, "/")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range c.mounts[namespace] {
+		if m.contains(path) {
+			return m, nil
+		}
+	}
+
+	m, err := ResolveMount(l, path)
+	if err != nil {
+		return Mount{}, err
+	}
+	c.mounts[namespace] = append(c.mounts[namespace], m)
+	return m, nil
+}
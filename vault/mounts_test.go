@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestMountAPIPath(t *testing.T) {
+	for name, tbl := range map[string]struct {
+		mount    Mount
+		path     string
+		expected string
+	}{
+		"kv-v1":         {mount: Mount{Path: "secret/", EngineType: EngineTypeKeyValueV1}, path: "secret/foo", expected: "secret/foo"},
+		"kv-v2":         {mount: Mount{Path: "secret/", EngineType: EngineTypeKeyValueV2}, path: "secret/foo/bar", expected: "secret/data/foo/bar"},
+		"kv-v2-api":     {mount: Mount{Path: "secret/", EngineType: EngineTypeKeyValueV2}, path: "secret/data/foo", expected: "secret/data/foo"},
+		"kv-v2-nested":  {mount: Mount{Path: "team/kv/", EngineType: EngineTypeKeyValueV2}, path: "team/kv/foo", expected: "team/kv/data/foo"},
+		"kv-v2-dataish": {mount: Mount{Path: "secret/", EngineType: EngineTypeKeyValueV2}, path: "secret/database", expected: "secret/data/database"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if p := tbl.mount.APIPath(tbl.path); p != tbl.expected {
+				t.Fatalf("expected %s, got %s", tbl.expected, p)
+			}
+		})
+	}
+}
+
+// countingLogical counts reads made through it.
+type countingLogical struct {
+	Logical
+	reads int
+}
+
+func (c *countingLogical) Read(path string) (*api.Secret, error) {
+	c.reads++
+	return c.Logical.Read(path)
+}
+
+func TestMountCache(t *testing.T) {
+	l := &countingLogical{Logical: NewMock(map[string]EngineType{
+		"kv1": EngineTypeKeyValueV1,
+		"kv2": EngineTypeKeyValueV2,
+	})}
+	c := NewMountCache()
+
+	m, err := c.Resolve(l, "", "kv2/foo")
+	if err != nil {
+		t.Fatalf("error resolving mount: %s", err)
+	}
+	if m.Path != "kv2/" || m.EngineType != EngineTypeKeyValueV2 {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+
+	m, err = c.Resolve(l, "", "/kv1/foo")
+	if err != nil {
+		t.Fatalf("error resolving mount: %s", err)
+	}
+	if m.Path != "kv1/" || m.EngineType != EngineTypeKeyValueV1 {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+
+	// other paths in the same mount should be cached
+	if _, err := c.Resolve(l, "", "kv2/bar/baz"); err != nil {
+		t.Fatalf("error resolving mount: %s", err)
+	}
+	if l.reads != 2 {
+		t.Fatalf("expected 2 lookups, got %d", l.reads)
+	}
+
+	// ...but not in other namespaces
+	if _, err := c.Resolve(l, "team-a", "kv2/bar"); err != nil {
+		t.Fatalf("error resolving mount: %s", err)
+	}
+	if l.reads != 3 {
+		t.Fatalf("expected 3 lookups, got %d", l.reads)
+	}
+
+	if _, err := c.Resolve(l, "", "nope/foo"); err == nil {
+		t.Fatal("expected an error for a path without a mount")
+	}
+}
+
+func TestResolveMountUnsupported(t *testing.T) {
+	for name, data := range map[string]map[string]any{
+		"transit": {"path": "transit/", "type": "transit"},
+		"no-path": {"type": "kv"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := &staticLogical{secret: &api.Secret{Data: data}}
+			if _, err := ResolveMount(l, "transit/foo"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	// mounts of the generic engine, and kv mounts without options, are v1
+	m, err := ResolveMount(&staticLogical{secret: &api.Secret{Data: map[string]any{
+		"path": "secret",
+		"type": "generic",
+	}}}, "secret/foo")
+	if err != nil {
+		t.Fatalf("error resolving mount: %s", err)
+	}
+	if m.Path != "secret/" || m.EngineType != EngineTypeKeyValueV1 {
+		t.Fatalf("unexpected mount: %+v", m)
+	}
+}
+
+// staticLogical returns the same secret for every read.
+type staticLogical struct {
+	Logical
+	secret *api.Secret
+}
+
+func (s *staticLogical) Read(string) (*api.Secret, error) {
+	return s.secret, nil
+}
+
+func TestMountPaths(t *testing.T) {
+	kv1 := Mount{Path: "secret/", EngineType: EngineTypeKeyValueV1}
+	kv2 := Mount{Path: "secret/", EngineType: EngineTypeKeyValueV2}
+
+	for name, tbl := range map[string]struct {
+		fn       func(string) string
+		path     string
+		expected string
+	}{
+		"kv-v1-data":        {fn: kv1.DataPath, path: "secret/foo", expected: "secret/foo"},
+		"kv-v1-list":        {fn: kv1.ListPath, path: "secret/foo/", expected: "secret/foo/"},
+		"kv-v1-logical":     {fn: kv1.LogicalPath, path: "secret/data/foo", expected: "secret/data/foo"},
+		"kv-v2-data":        {fn: kv2.DataPath, path: "secret/data/foo", expected: "secret/data/data/foo"},
+		"kv-v2-list":        {fn: kv2.ListPath, path: "secret/foo/", expected: "secret/metadata/foo/"},
+		"kv-v2-list-mount":  {fn: kv2.ListPath, path: "secret/", expected: "secret/metadata/"},
+		"kv-v2-logical":     {fn: kv2.LogicalPath, path: "secret/data/foo", expected: "secret/foo"},
+		"kv-v2-logical-md":  {fn: kv2.LogicalPath, path: "secret/metadata/foo/", expected: "secret/foo/"},
+		"kv-v2-logical-bar": {fn: kv2.LogicalPath, path: "secret/data", expected: "secret/"},
+		"kv-v2-logical-not": {fn: kv2.LogicalPath, path: "secret/foo", expected: "secret/foo"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if p := tbl.fn(tbl.path); p != tbl.expected {
+				t.Fatalf("expected %s, got %s", tbl.expected, p)
+			}
+		})
+	}
+}
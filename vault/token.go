@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// DefaultLoginRetryInterval is how long a TokenManager waits before trying
+// to log in again after a failed login.
+const DefaultLoginRetryInterval = 10 * time.Second
+
+// TokenManager keeps a vault client's token valid for as long as it's
+// needed.  It renews the token's lease before it expires, logs in again with
+// its Authenticator when the token can't be renewed (because renewal failed,
+// the token was revoked or it reached its maximum TTL), and revokes the token
+// when it's stopped.
+type TokenManager struct {
+	client *api.Client
+	auth   Authenticator
+
+	// RetryInterval is how long to wait before trying to log in again after
+	// a failed login.  Defaults to DefaultLoginRetryInterval.
+	RetryInterval time.Duration
+
+	// Namespace is the Vault Enterprise namespace in which to log in and
+	// manage the token, which may differ from the namespaces that secrets are
+	// read from.  The root namespace is used if it's empty.
+	Namespace string
+
+	secret *api.Secret
+}
+
+// NewTokenManager returns a new TokenManager that sets client's token by
+// logging in with auth.
+func NewTokenManager(client *api.Client, auth Authenticator) *TokenManager {
+	return &TokenManager{
+		client:        client,
+		auth:          auth,
+		RetryInterval: DefaultLoginRetryInterval,
+	}
+}
+
+// Login logs in to vault and sets the client's token.  It must be called
+// before Run, and not concurrently with it.
+func (t *TokenManager) Login() error {
+	// Log in with a client that has no token, since the current one may
+	// have been revoked.
+	loginClient, err := t.client.CloneWithHeaders()
+	if err != nil {
+		return err
+	}
+	loginClient.ClearToken()
+	if t.Namespace != "" {
+		loginClient.SetNamespace(t.Namespace)
+	}
+
+	secret, err := t.auth.Login(loginClient.Logical())
+	if err != nil {
+		return err
+	}
+
+	t.secret = secret
+	t.client.SetToken(secret.Auth.ClientToken)
+	return nil
+}
+
+// Run keeps the token valid until ctx is cancelled, at which point it revokes
+// the token and returns.
+func (t *TokenManager) Run(ctx context.Context) {
+	defer t.revoke()
+
+	for {
+		wait, expires := t.refreshAfter()
+		if !expires {
+			// the token doesn't have a TTL, so it never needs refreshing
+			<-ctx.Done()
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if t.renew() {
+			continue
+		}
+		t.relogin(ctx)
+	}
+}
+
+// refreshAfter returns how long to wait before refreshing the token, which is
+// two thirds of its lease so that there's time to retry, and whether it
+// expires at all.
+func (t *TokenManager) refreshAfter() (time.Duration, bool) {
+	lease := time.Duration(t.secret.Auth.LeaseDuration) * time.Second
+	if lease <= 0 {
+		return 0, false
+	}
+	return lease * 2 / 3, true
+}
+
+// renew tries to renew the token's lease, and returns true if that extended
+// it by the full lease duration.
+func (t *TokenManager) renew() bool {
+	if !t.secret.Auth.Renewable {
+		return false
+	}
+
+	increment := t.secret.Auth.LeaseDuration
+	secret, err := t.tokenClient().Auth().Token().RenewSelf(increment)
+	if err != nil {
+		log.Printf("error renewing vault token: %s", err)
+		return false
+	}
+	if secret == nil || secret.Auth == nil {
+		log.Printf("error renewing vault token: no auth information returned")
+		return false
+	}
+	if secret.Auth.LeaseDuration < increment {
+		// the token is close to its maximum TTL, so we need a new one
+		return false
+	}
+
+	t.secret = secret
+	return true
+}
+
+// relogin logs in again until it succeeds or ctx is cancelled.  The current
+// token is kept until a new one is available.
+func (t *TokenManager) relogin(ctx context.Context) {
+	retryInterval := t.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultLoginRetryInterval
+	}
+
+	for {
+		err := t.Login()
+		if err == nil {
+			log.Printf("logged in to vault with a new token")
+			return
+		}
+		log.Printf(
+			"error logging in to vault, retrying in %s: %s",
+			retryInterval,
+			err,
+		)
+
+		timer := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// revoke revokes the token so that it can't be used after we're done with it.
+func (t *TokenManager) revoke() {
+	if err := t.tokenClient().Auth().Token().RevokeSelf(""); err != nil {
+		log.Printf("error revoking vault token: %s", err)
+		return
+	}
+	t.client.ClearToken()
+}
+
+// tokenClient returns a client for managing the current token in the
+// namespace it was issued in.
+func (t *TokenManager) tokenClient() *api.Client {
+	if t.Namespace == "" {
+		return t.client
+	}
+	return t.client.WithNamespace(t.Namespace)
+}
@@ -0,0 +1,244 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// tokenVault is a fake vault server that issues tokens via an approle login
+// and supports renewing and revoking them.
+type tokenVault struct {
+	mu          sync.Mutex
+	logins      int
+	renewals    int
+	revoked     []string
+	namespaces  []string // namespaces of all requests
+	lease       int      // lease duration of issued tokens, in seconds
+	renewLease  int      // lease duration returned by renewals, in seconds
+	failRenewal bool     // reject renewals as if the token had been revoked
+}
+
+func (v *tokenVault) serve(t testing.TB) *api.Client {
+	t.Helper()
+
+	writeAuth := func(w http.ResponseWriter, token string, lease int) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{
+				"client_token":   token,
+				"lease_duration": lease,
+				"renewable":      true,
+			},
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		v.namespaces = append(v.namespaces, req.Header.Get(api.NamespaceHeaderName))
+		switch req.URL.Path {
+		case "/v1/auth/approle/login":
+			if req.Header.Get("X-Vault-Token") != "" {
+				http.Error(w, `{"errors":["unexpected token"]}`, http.StatusBadRequest)
+				return
+			}
+			v.logins++
+			writeAuth(w, fmt.Sprintf("s.token-%d", v.logins), v.lease)
+		case "/v1/auth/token/renew-self":
+			if v.failRenewal {
+				http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+			v.renewals++
+			writeAuth(w, req.Header.Get("X-Vault-Token"), v.renewLease)
+		case "/v1/auth/token/revoke-self":
+			v.revoked = append(v.revoked, req.Header.Get("X-Vault-Token"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := api.DefaultConfig()
+	c.Address = srv.URL
+	client, err := api.NewClient(c)
+	if err != nil {
+		t.Fatalf("error creating vault client: %s", err)
+	}
+	client.ClearToken()
+	return client
+}
+
+func (v *tokenVault) counts() (logins, renewals int, revoked []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.logins, v.renewals, append([]string(nil), v.revoked...)
+}
+
+func testAppRoleAuth(t testing.TB) AppRoleAuth {
+	t.Setenv("PENTAGON_TEST_ROLE_ID", "role")
+	t.Setenv("PENTAGON_TEST_SECRET_ID", "secret")
+	return AppRoleAuth{
+		RoleID:   CredentialSource{Env: "PENTAGON_TEST_ROLE_ID"},
+		SecretID: CredentialSource{Env: "PENTAGON_TEST_SECRET_ID"},
+	}
+}
+
+// runTokenManager runs tm until check returns true or the test times out,
+// and then stops it.
+func runTokenManager(t *testing.T, tm *TokenManager, check func() bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tm.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			cancel()
+			<-done
+			t.Fatal("timed out waiting for the token manager")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestTokenManagerRenews(t *testing.T) {
+	v := &tokenVault{lease: 1, renewLease: 1}
+	client := v.serve(t)
+
+	tm := NewTokenManager(client, testAppRoleAuth(t))
+	if err := tm.Login(); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if client.Token() != "s.token-1" {
+		t.Fatalf("unexpected token: %s", client.Token())
+	}
+
+	runTokenManager(t, tm, func() bool {
+		_, renewals, _ := v.counts()
+		return renewals >= 1
+	})
+
+	logins, _, revoked := v.counts()
+	if logins != 1 {
+		t.Errorf("expected renewal rather than re-login, got %d logins", logins)
+	}
+	if len(revoked) != 1 || revoked[0] != "s.token-1" {
+		t.Errorf("expected the token to be revoked on shutdown, got %v", revoked)
+	}
+	if client.Token() != "" {
+		t.Errorf("expected the revoked token to be cleared, got %s", client.Token())
+	}
+}
+
+func TestTokenManagerReloginOnRenewalFailure(t *testing.T) {
+	v := &tokenVault{lease: 1, renewLease: 1, failRenewal: true}
+	client := v.serve(t)
+
+	tm := NewTokenManager(client, testAppRoleAuth(t))
+	if err := tm.Login(); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	runTokenManager(t, tm, func() bool {
+		logins, _, _ := v.counts()
+		return logins >= 2
+	})
+
+	_, _, revoked := v.counts()
+	if len(revoked) != 1 || revoked[0] != "s.token-2" {
+		t.Errorf("expected the new token to be revoked on shutdown, got %v", revoked)
+	}
+}
+
+func TestTokenManagerReloginAtMaxTTL(t *testing.T) {
+	// renewals don't extend the lease by the full increment, so the token is
+	// about to reach its maximum TTL
+	v := &tokenVault{lease: 1, renewLease: 0}
+	client := v.serve(t)
+
+	tm := NewTokenManager(client, testAppRoleAuth(t))
+	if err := tm.Login(); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	runTokenManager(t, tm, func() bool {
+		logins, _, _ := v.counts()
+		return logins >= 2
+	})
+
+	if client.Token() != "" {
+		t.Errorf("expected the revoked token to be cleared, got %s", client.Token())
+	}
+}
+
+func TestTokenManagerNoTTL(t *testing.T) {
+	v := &tokenVault{lease: 0}
+	client := v.serve(t)
+
+	tm := NewTokenManager(client, testAppRoleAuth(t))
+	if err := tm.Login(); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	tm.Run(ctx)
+
+	logins, renewals, revoked := v.counts()
+	if logins != 1 || renewals != 0 {
+		t.Errorf("expected a token without a TTL to be left alone, got %d logins and %d renewals", logins, renewals)
+	}
+	if len(revoked) != 1 {
+		t.Errorf("expected the token to be revoked on shutdown, got %v", revoked)
+	}
+}
+
+func TestTokenManagerNamespace(t *testing.T) {
+	v := &tokenVault{lease: 1, renewLease: 1}
+	client := v.serve(t)
+
+	tm := NewTokenManager(client, testAppRoleAuth(t))
+	tm.Namespace = "admin"
+	if err := tm.Login(); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	runTokenManager(t, tm, func() bool {
+		_, renewals, _ := v.counts()
+		return renewals >= 1
+	})
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// login, renewal and revocation
+	if len(v.namespaces) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(v.namespaces))
+	}
+	for _, ns := range v.namespaces {
+		if ns != "admin" {
+			t.Fatalf("expected all requests in namespace admin, got %v", v.namespaces)
+		}
+	}
+	if client.Namespace() != "" {
+		t.Fatalf("the client's namespace should be left alone, is %q", client.Namespace())
+	}
+}
@@ -1,9 +1,14 @@
 package vault
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 )
@@ -17,6 +22,10 @@ const (
 
 	// EngineTypeKeyValueV2 is the identifier for version 2 of the key/value engine.
 	EngineTypeKeyValueV2 EngineType = "kv-v2"
+
+	// EngineTypeAuto isn't an engine, but means that the type of the engine
+	// should be detected by looking up the mount a path belongs to.
+	EngineTypeAuto EngineType = "auto"
 )
 
 // AllEngineTypes is a slice of all the engine types known to pentagon.
@@ -35,6 +44,15 @@ const (
 	// to be populated with the role that vault expects and will use the machine's
 	// default service account, running within GCP.
 	AuthTypeGCPDefault AuthType = "gcp-default"
+
+	// AuthTypeKubernetes expects the Role property of the VaultConfig struct
+	// to be populated with the role that vault expects and will log in with
+	// the pod's kubernetes service account token.
+	AuthTypeKubernetes AuthType = "kubernetes"
+
+	// AuthTypeAppRole expects the AppRole property of the VaultConfig struct
+	// to describe where to read the role_id and secret_id from.
+	AuthTypeAppRole AuthType = "approle"
 )
 
 func init() {
@@ -51,65 +69,363 @@ type Logical interface {
 	Write(string, map[string]any) (*api.Secret, error)
 }
 
-// Mock is a mock vault of secrets.
+// Lister is implemented by Logicals that can list the keys under a path,
+// which is needed to mirror a subtree of secrets.
+type Lister interface {
+	List(string) (*api.Secret, error)
+}
+
+// DataReader is implemented by Logicals that can pass query parameters when
+// reading a secret, which is how a version of a key/value v2 secret is read.
+type DataReader interface {
+	ReadWithData(string, map[string][]string) (*api.Secret, error)
+}
+
+// Namespacer is implemented by Logicals that can target a Vault Enterprise
+// namespace.
+type Namespacer interface {
+	// WithNamespace returns a Logical whose requests are made in namespace.
+	WithNamespace(namespace string) Logical
+}
+
+// Mock is a mock vault of secrets.  Secrets in key/value v2 mounts are
+// versioned like they are in vault.
 type Mock struct {
 	contents     map[string]*api.Secret
+	versions     map[string][]*mockVersion
+	metadata     map[string]map[string]any
 	engineMounts map[string]EngineType
 	mu           sync.RWMutex // for synchronizing if anyone cares
 }
 
+// mockVersion is a version of a key/value v2 secret.
+type mockVersion struct {
+	data      map[string]any
+	created   time.Time
+	deleted   time.Time
+	destroyed bool
+}
+
 // NewMock returns a new mock vault client.  engineMounts is a map of the path
 // prefix to the type of secrets engine that is mounted.
 func NewMock(engineMounts map[string]EngineType) *Mock {
 	return &Mock{
 		contents:     map[string]*api.Secret{},
+		versions:     map[string][]*mockVersion{},
+		metadata:     map[string]map[string]any{},
 		engineMounts: engineMounts,
 	}
 }
 
 // Read reads secrets from the mock vault.
 func (m *Mock) Read(path string) (*api.Secret, error) {
+	return m.read("", path, nil)
+}
+
+// ReadWithData reads secrets from the mock vault.  The "version" parameter
+// selects a version of a key/value v2 secret.
+func (m *Mock) ReadWithData(path string, data map[string][]string) (*api.Secret, error) {
+	return m.read("", path, data)
+}
+
+// List lists the keys under path in the mock vault.  Like vault, keys
+// containing further keys have a trailing slash, and key/value v2 secrets are
+// listed with <mount>/metadata/<path>.
+func (m *Mock) List(path string) (*api.Secret, error) {
+	return m.list("", path)
+}
+
+// Write writes secrets into the mock vault.  For key/value v2 mounts this
+// creates a new version of the secret, and writing the custom_metadata of
+// <mount>/metadata/<path> sets the custom metadata of <mount>/data/<path>.
+func (m *Mock) Write(
+	path string,
+	data map[string]any,
+) (*api.Secret, error) {
+	return m.write("", path, data)
+}
+
+// Delete removes the secret at path.  For key/value v2 mounts this removes
+// all versions and the metadata, like deleting <mount>/metadata/<path> does.
+func (m *Mock) Delete(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.contents, path)
+	delete(m.versions, path)
+	delete(m.metadata, path)
+}
+
+// DeleteVersion soft-deletes a version of the key/value v2 secret at path.
+func (m *Mock) DeleteVersion(path string, version int) error {
+	return m.updateVersion(path, version, func(v *mockVersion) {
+		v.deleted = time.Now()
+	})
+}
+
+// DestroyVersion permanently destroys a version of the key/value v2 secret at
+// path.
+func (m *Mock) DestroyVersion(path string, version int) error {
+	return m.updateVersion(path, version, func(v *mockVersion) {
+		v.data = nil
+		v.destroyed = true
+	})
+}
+
+// WithNamespace returns a view of the mock vault within a Vault Enterprise
+// namespace.  Secrets written in a namespace are only visible within it, but
+// the engine mounts are shared by all namespaces.
+func (m *Mock) WithNamespace(namespace string) Logical {
+	return &mockNamespace{
+		mock:      m,
+		namespace: strings.Trim(namespace, "/"),
+	}
+}
+
+func (m *Mock) read(namespace, path string, params map[string][]string) (*api.Secret, error) {
+	if strings.HasPrefix(path, mountsPath) {
+		return m.mount(strings.TrimPrefix(path, mountsPath))
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	key := namespacedPath(namespace, path)
+	if m.engineType(path) == EngineTypeKeyValueV2 {
+		return m.readVersion(key, params)
+	}
+
 	// note that the actual vault client returns (nil, nil) when the secret
 	// isn't found
-	if secret, found := m.contents[path]; found {
+	if secret, found := m.contents[key]; found {
 		return secret, nil
 	}
 
 	return nil, nil
 }
 
-// Write writes secrets into the mock vault.
-func (m *Mock) Write(
-	path string,
-	data map[string]any,
-) (*api.Secret, error) {
+func (m *Mock) list(namespace, path string) (*api.Secret, error) {
+	prefix := strings.TrimSuffix(namespacedPath(namespace, path), "/") + "/"
 
-	var secret *api.Secret
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	splitPath := strings.Split(path, "/")
+	var keys []string
+	if m.engineType(path) == EngineTypeKeyValueV2 {
+		dataPrefix, ok := metadataToDataPath(prefix)
+		if !ok {
+			return nil, fmt.Errorf("key/value v2 secrets are listed under <mount>/metadata/")
+		}
+		prefix = dataPrefix
+		keys = slices.Collect(maps.Keys(m.versions))
+	} else {
+		keys = slices.Collect(maps.Keys(m.contents))
+	}
 
-	engineType := m.engineMounts[splitPath[0]]
+	children := map[string]struct{}{}
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			children[child+"/"] = struct{}{}
+		} else {
+			children[child] = struct{}{}
+		}
+	}
+
+	// note that the actual vault client returns (nil, nil) when there's
+	// nothing to list
+	if len(children) == 0 {
+		return nil, nil
+	}
+
+	listed := []any{}
+	for _, child := range slices.Sorted(maps.Keys(children)) {
+		listed = append(listed, child)
+	}
+	return &api.Secret{
+		Data: map[string]any{
+			"keys": listed,
+		},
+	}, nil
+}
+
+// readVersion reads a version of a key/value v2 secret, which defaults to the
+// latest one.  Like vault, deleted and destroyed versions are returned
+// without data.
+func (m *Mock) readVersion(key string, params map[string][]string) (*api.Secret, error) {
+	versions := m.versions[key]
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	version := len(versions)
+	if v := params["version"]; len(v) > 0 && v[0] != "0" {
+		var err error
+		version, err = strconv.Atoi(v[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", v[0])
+		}
+	}
+	if version < 1 || version > len(versions) {
+		return nil, nil
+	}
+
+	v := versions[version-1]
+	deletionTime := ""
+	if !v.deleted.IsZero() {
+		deletionTime = v.deleted.UTC().Format(time.RFC3339Nano)
+	}
+	var data any
+	if deletionTime == "" && !v.destroyed {
+		data = v.data
+	}
+
+	return &api.Secret{
+		Data: map[string]any{
+			"data": data,
+			"metadata": map[string]any{
+				"version":         json.Number(strconv.Itoa(version)),
+				"created_time":    v.created.UTC().Format(time.RFC3339Nano),
+				"deletion_time":   deletionTime,
+				"destroyed":       v.destroyed,
+				"custom_metadata": m.metadata[key],
+			},
+		},
+	}, nil
+}
+
+func (m *Mock) write(
+	namespace, path string,
+	data map[string]any,
+) (*api.Secret, error) {
+	key := namespacedPath(namespace, path)
+
+	engineType := m.engineType(path)
 	switch engineType {
 	case EngineTypeKeyValueV1:
-		secret = &api.Secret{
+		secret := &api.Secret{
 			Data: data,
 		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.contents[key] = secret
+		return secret, nil
 	case EngineTypeKeyValueV2:
-		secret = &api.Secret{
-			Data: map[string]any{
-				"data": data,
-			},
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if dataKey, ok := metadataToDataPath(key); ok {
+			custom, _ := data["custom_metadata"].(map[string]any)
+			m.metadata[dataKey] = custom
+			return nil, nil
 		}
+
+		m.versions[key] = append(m.versions[key], &mockVersion{
+			data:    data,
+			created: time.Now(),
+		})
+		return m.readVersion(key, nil)
 	default:
 		return nil, fmt.Errorf("unknown engine: %s", engineType)
 	}
+}
 
+// updateVersion calls update with a version of the key/value v2 secret at
+// path.
+func (m *Mock) updateVersion(path string, version int, update func(*mockVersion)) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.contents[path] = secret
-	return secret, nil
+	versions := m.versions[path]
+	if version < 1 || version > len(versions) {
+		return fmt.Errorf("version %d of %s not found", version, path)
+	}
+	update(versions[version-1])
+	return nil
+}
+
+// engineType returns the type of the engine mounted at the first component
+// of path.
+func (m *Mock) engineType(path string) EngineType {
+	mountPath, _, _ := strings.Cut(path, "/")
+	return m.engineMounts[mountPath]
+}
+
+// mount describes the engine mounted at the first component of path like
+// vault's sys/internal/ui/mounts endpoint does.
+func (m *Mock) mount(path string) (*api.Secret, error) {
+	mountPath, _, _ := strings.Cut(path, "/")
+
+	version := ""
+	switch m.engineMounts[mountPath] {
+	case EngineTypeKeyValueV1:
+		version = "1"
+	case EngineTypeKeyValueV2:
+		version = "2"
+	default:
+		return nil, fmt.Errorf("no mount found for %s", path)
+	}
+
+	return &api.Secret{
+		Data: map[string]any{
+			"path": mountPath + "/",
+			"type": "kv",
+			"options": map[string]any{
+				"version": version,
+			},
+		},
+	}, nil
+}
+
+// metadataToDataPath returns the path of the secret whose metadata is at
+// <mount>/metadata/<path>, and whether key is such a metadata path.
+func metadataToDataPath(key string) (string, bool) {
+	before, after, ok := strings.Cut(key, "/metadata/")
+	if !ok {
+		return "", false
+	}
+	return before + "/data/" + after, true
+}
+
+// namespacedPath returns the key under which the mock stores path within
+// namespace.
+func namespacedPath(namespace, path string) string {
+	if namespace == "" {
+		return path
+	}
+	return namespace + "/" + path
+}
+
+// mockNamespace is a Mock within a namespace.
+type mockNamespace struct {
+	mock      *Mock
+	namespace string
+}
+
+// Read reads secrets from the namespace.
+func (n *mockNamespace) Read(path string) (*api.Secret, error) {
+	return n.mock.read(n.namespace, path, nil)
+}
+
+// ReadWithData reads secrets from the namespace.
+func (n *mockNamespace) ReadWithData(path string, data map[string][]string) (*api.Secret, error) {
+	return n.mock.read(n.namespace, path, data)
+}
+
+// List lists the keys under path in the namespace.
+func (n *mockNamespace) List(path string) (*api.Secret, error) {
+	return n.mock.list(n.namespace, path)
+}
+
+// Write writes secrets into the namespace.
+func (n *mockNamespace) Write(
+	path string,
+	data map[string]any,
+) (*api.Secret, error) {
+	return n.mock.write(n.namespace, path, data)
 }
@@ -79,3 +79,92 @@ func TestReadNotFound(t *testing.T) {
 		t.Fatal("err should be nil")
 	}
 }
+
+func TestMockNamespaces(t *testing.T) {
+	m := NewMock(map[string]EngineType{
+		"kv1": EngineTypeKeyValueV1,
+	})
+
+	if _, err := m.Write("kv1/test", map[string]any{"ns": "root"}); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	if _, err := m.WithNamespace("team-a").Write("kv1/test", map[string]any{"ns": "team-a"}); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+
+	for ns, expected := range map[string]string{
+		"":         "root",
+		"team-a":   "team-a",
+		"/team-a/": "team-a",
+	} {
+		var l Logical = m
+		if ns != "" {
+			l = m.WithNamespace(ns)
+		}
+		s, err := l.Read("kv1/test")
+		if err != nil {
+			t.Fatalf("error reading in namespace %q: %s", ns, err)
+		}
+		if s.Data["ns"] != expected {
+			t.Fatalf("expected %q in namespace %q, got %v", expected, ns, s.Data["ns"])
+		}
+	}
+
+	s, err := m.WithNamespace("team-b").Read("kv1/test")
+	if err != nil || s != nil {
+		t.Fatalf("expected no secret in another namespace, got %v (err: %v)", s, err)
+	}
+}
+
+func TestMockVersions(t *testing.T) {
+	m := NewMock(map[string]EngineType{
+		"kv2": EngineTypeKeyValueV2,
+	})
+
+	for _, v := range []string{"one", "two", "three"} {
+		if _, err := m.Write("kv2/data/test", map[string]any{"v": v}); err != nil {
+			t.Fatalf("error writing: %s", err)
+		}
+	}
+
+	read := func(version string) *api.Secret {
+		t.Helper()
+		s, err := m.ReadWithData("kv2/data/test", map[string][]string{"version": {version}})
+		if err != nil {
+			t.Fatalf("error reading version %s: %s", version, err)
+		}
+		return s
+	}
+
+	for version, expected := range map[string]string{"0": "three", "1": "one", "3": "three"} {
+		data, _ := read(version).Data["data"].(map[string]any)
+		if data["v"] != expected {
+			t.Fatalf("expected %q for version %s, got %v", expected, version, data["v"])
+		}
+	}
+	if s := read("4"); s != nil {
+		t.Fatalf("expected no secret for a missing version, got %v", s)
+	}
+
+	if err := m.DeleteVersion("kv2/data/test", 2); err != nil {
+		t.Fatalf("error deleting: %s", err)
+	}
+	if err := m.DestroyVersion("kv2/data/test", 1); err != nil {
+		t.Fatalf("error destroying: %s", err)
+	}
+	if err := m.DestroyVersion("kv2/data/test", 5); err == nil {
+		t.Fatal("expected an error destroying a missing version")
+	}
+
+	deleted := read("2")
+	if deleted.Data["data"] != nil {
+		t.Fatalf("expected no data for a deleted version, got %v", deleted.Data["data"])
+	}
+	if md := deleted.Data["metadata"].(map[string]any); md["deletion_time"] == "" {
+		t.Fatal("expected a deletion time for a deleted version")
+	}
+	destroyed := read("1")
+	if md := destroyed.Data["metadata"].(map[string]any); md["destroyed"] != true {
+		t.Fatal("expected a destroyed version to be marked as such")
+	}
+}
@@ -0,0 +1,150 @@
+package pentagon
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/vimeo/pentagon/vault"
+)
+
+// AnnotationVaultListPath is the path of the VaultList mapping that a secret
+// was generated from.  If that path can't be listed, the secrets generated
+// from it are kept rather than being deleted by reconciliation.
+const AnnotationVaultListPath = AnnotationPrefix + "vault-list-path"
+
+// secretNameData is what a SecretNameTemplate is executed with.
+type secretNameData struct {
+	// Path is the path of the Vault secret relative to the mapping's path,
+	// or the ID of the GSM secret.
+	Path string
+
+	// Name is the last element of Path.
+	Name string
+
+	// Labels are the labels of the GSM secret.
+	Labels map[string]string
+}
+
+// parseSecretNameTemplate parses a SecretNameTemplate, which defaults to
+// DefaultSecretNameTemplate.
+func parseSecretNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultSecretNameTemplate
+	}
+	return template.New("secretName").Option("missingkey=error").Parse(text)
+}
+
+// expandVaultList lists the Vault secrets under the path of a VaultList
+// mapping, and returns a mapping for each of them.
+func (r *Reflector) expandVaultList(mapping Mapping) ([]Mapping, error) {
+	namer, err := newSecretNamer(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	logical, err := r.vaultLogical(mapping)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := logical.(vault.Lister)
+	if !ok {
+		return nil, fmt.Errorf("vault client does not support listing (path %s)", mapping.Path)
+	}
+
+	// the mount is needed to find the list and data paths of key/value v2
+	// secrets, whatever the engine type.
+	mount, err := r.vaultMounts.Resolve(logical, mapping.VaultNamespace, mapping.Path)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.VaultEngineType != vault.EngineTypeAuto && mapping.VaultEngineType != mount.EngineType {
+		return nil, fmt.Errorf(
+			"vault engine type %q doesn't match the %q engine mounted at %s",
+			mapping.VaultEngineType,
+			mount.EngineType,
+			mount.Path,
+		)
+	}
+
+	prefix := strings.TrimSuffix(mount.LogicalPath(mapping.Path), "/")
+	paths, err := vault.ListSecrets(
+		lister,
+		mount,
+		prefix,
+		mapping.VaultList == VaultListRecursive,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make([]Mapping, 0, len(paths))
+	for _, p := range paths {
+		name, err := namer.name("vault secret "+p, secretNameData{
+			Path: p,
+			Name: path.Base(p),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		m := mapping
+		m.Path = mount.DataPath(prefix + "/" + p)
+		m.SecretName = name
+		m.VaultEngineType = mount.EngineType
+		m.VaultList = ""
+		m.SecretNameTemplate = ""
+		m.listPath = mapping.Path
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
+
+// executeSecretNameTemplate executes tmpl with data, and makes the result a
+// valid kubernetes secret name.
+func executeSecretNameTemplate(tmpl *template.Template, data secretNameData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	name := sanitizeSecretName(b.String())
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid secret name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// sanitizeSecretName turns s into a DNS-1123 subdomain, which is what
+// kubernetes requires secret names to be, by lowercasing it, replacing
+// invalid characters with dashes and removing dashes from the ends of its
+// dot-separated labels.
+func sanitizeSecretName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, s)
+
+	var labels []string
+	for _, label := range strings.Split(s, ".") {
+		if label = strings.Trim(label, "-"); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	s = strings.Join(labels, ".")
+
+	if len(s) > validation.DNS1123SubdomainMaxLength {
+		s = strings.TrimRight(s[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+	return s
+}
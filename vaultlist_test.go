@@ -0,0 +1,254 @@
+package pentagon
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vimeo/pentagon/gsm"
+	"github.com/vimeo/pentagon/vault"
+)
+
+func TestSanitizeSecretName(t *testing.T) {
+	for in, expected := range map[string]string{
+		"foo":                    "foo",
+		"team/Foo_Bar":           "team-foo-bar",
+		"/leading/":              "leading",
+		"a..b":                   "a.b",
+		"a.-b-.c":                "a.b.c",
+		"tls.crt":                "tls.crt",
+		"émoji 🔑":                "moji",
+		strings.Repeat("a", 300): strings.Repeat("a", 253),
+	} {
+		if name := sanitizeSecretName(in); name != expected {
+			t.Errorf("sanitizeSecretName(%q): expected %q, got %q", in, expected, name)
+		}
+	}
+}
+
+func TestExecuteSecretNameTemplate(t *testing.T) {
+	for tmplText, expected := range map[string]string{
+		"":                               "team-foo-bar",
+		"{{ .Path }}":                    "team-foo-bar",
+		"{{ .Name }}":                    "bar",
+		"myapp-{{ .Path }}":              "myapp-team-foo-bar",
+		`{{ .Name | printf "%s" }}`:      "bar",
+		"{{ .Labels.team }}-{{ .Name }}": "infra-bar",
+	} {
+		tmpl, err := parseSecretNameTemplate(tmplText)
+		if err != nil {
+			t.Fatalf("error parsing %q: %s", tmplText, err)
+		}
+		name, err := executeSecretNameTemplate(tmpl, secretNameData{
+			Path:   "team/foo/bar",
+			Name:   "bar",
+			Labels: map[string]string{"team": "infra"},
+		})
+		if err != nil {
+			t.Fatalf("error executing %q: %s", tmplText, err)
+		}
+		if name != expected {
+			t.Errorf("%q: expected %q, got %q", tmplText, expected, name)
+		}
+	}
+
+	for _, tmplText := range []string{"{{ .Missing }}", "{{ .Labels.missing }}", "---"} {
+		tmpl, err := parseSecretNameTemplate(tmplText)
+		if err != nil {
+			t.Fatalf("error parsing %q: %s", tmplText, err)
+		}
+		if _, err := executeSecretNameTemplate(tmpl, secretNameData{Path: "foo", Name: "foo"}); err == nil {
+			t.Errorf("%q: expected an error", tmplText)
+		}
+	}
+}
+
+func TestReflectorVaultList(t *testing.T) {
+	for _, engineType := range vault.AllEngineTypes {
+		t.Run(string(engineType), func(t *testing.T) {
+			ctx := context.Background()
+			k8sClient := k8sfake.NewClientset()
+
+			vaultClient := vault.NewMock(map[string]vault.EngineType{
+				"secrets": engineType,
+			})
+			mount := vault.Mount{Path: "secrets/", EngineType: engineType}
+			for _, p := range []string{"apps/foo", "apps/bar", "apps/team/baz"} {
+				vaultClient.Write(mount.DataPath("secrets/"+p), map[string]any{"name": p})
+			}
+
+			r := NewReflector(
+				vaultClient,
+				gsm.NewMockGSM(nil),
+				k8sClient, DefaultNamespace,
+				"test",
+			)
+
+			mappings := []Mapping{
+				{
+					SourceType:         VaultSourceType,
+					Path:               "secrets/apps",
+					VaultEngineType:    vault.EngineTypeAuto,
+					VaultList:          VaultListRecursive,
+					SecretNameTemplate: "apps-{{ .Path }}",
+				},
+				{
+					SourceType:         VaultSourceType,
+					Path:               "secrets/apps/",
+					VaultEngineType:    engineType,
+					VaultList:          VaultListOneLevel,
+					SecretNameTemplate: "{{ .Name }}",
+				},
+			}
+			if err := r.Reflect(ctx, mappings); err != nil {
+				t.Fatalf("reflect didn't work: %s", err)
+			}
+
+			secrets := k8sClient.CoreV1().Secrets(DefaultNamespace)
+			expected := map[string]string{
+				"apps-foo":      "apps/foo",
+				"apps-bar":      "apps/bar",
+				"apps-team-baz": "apps/team/baz",
+				"foo":           "apps/foo",
+				"bar":           "apps/bar",
+			}
+			checkSecrets := func() {
+				t.Helper()
+				list, err := secrets.List(ctx, metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("error listing secrets: %s", err)
+				}
+				var names []string
+				for _, s := range list.Items {
+					names = append(names, s.Name)
+					if string(s.Data["name"]) != expected[s.Name] {
+						t.Fatalf("secret %s should contain %q, got %q", s.Name, expected[s.Name], s.Data["name"])
+					}
+					if s.Annotations[AnnotationVaultListPath] == "" {
+						t.Fatalf("secret %s should be annotated with its list path", s.Name)
+					}
+				}
+				expectedNames := slices.Sorted(maps.Keys(expected))
+				slices.Sort(names)
+				if !slices.Equal(names, expectedNames) {
+					t.Fatalf("expected secrets %v, got %v", expectedNames, names)
+				}
+			}
+			checkSecrets()
+
+			// deleting a leaf in vault deletes its kubernetes secrets
+			vaultClient.Delete(mount.DataPath("secrets/apps/bar"))
+			if err := r.Reflect(ctx, mappings); err != nil {
+				t.Fatalf("reflect didn't work: %s", err)
+			}
+			delete(expected, "apps-bar")
+			delete(expected, "bar")
+			checkSecrets()
+
+			// secrets aren't deleted when their path can't be listed
+			r = NewReflector(
+				struct{ vault.Logical }{vault.NewMock(nil)},
+				gsm.NewMockGSM(nil),
+				k8sClient, DefaultNamespace,
+				"test",
+				WithContinueOnError(),
+			)
+			results, err := r.ReflectResults(ctx, mappings)
+			if err == nil {
+				t.Fatal("expected an error listing an unmounted path")
+			}
+			for _, result := range results {
+				if result.Action != ActionFailed {
+					t.Fatalf("unexpected result: %+v", result)
+				}
+			}
+			checkSecrets()
+		})
+	}
+}
+
+func TestReflectorVaultListCollision(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/apps/foo_bar", map[string]any{"k": "v"})
+	vaultClient.Write("secrets/apps/foo/bar", map[string]any{"k": "v"})
+
+	r := NewReflector(
+		vaultClient,
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+	)
+	err := r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/apps",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			VaultList:       VaultListRecursive,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "would both be written to kubernetes secret foo-bar") {
+		t.Fatalf("expected a name collision error, got %v", err)
+	}
+
+	// the engine type has to match the mount
+	err = r.Reflect(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/apps",
+			VaultEngineType: vault.EngineTypeKeyValueV2,
+			VaultList:       VaultListOneLevel,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an engine type mismatch error")
+	}
+}
+
+func TestReflectorVaultListUnsupported(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := k8sfake.NewClientset()
+
+	vaultClient := vault.NewMock(map[string]vault.EngineType{
+		"secrets": vault.EngineTypeKeyValueV1,
+	})
+	vaultClient.Write("secrets/apps/foo", map[string]any{"k": "v"})
+
+	// a client that can't list only fails the list mappings
+	r := NewReflector(
+		struct{ vault.Logical }{vaultClient},
+		gsm.NewMockGSM(nil),
+		k8sClient, DefaultNamespace,
+		DefaultLabelValue,
+		WithContinueOnError(),
+	)
+	results, err := r.ReflectResults(ctx, []Mapping{
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/apps",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+			VaultList:       VaultListOneLevel,
+		},
+		{
+			SourceType:      VaultSourceType,
+			Path:            "secrets/apps/foo",
+			SecretName:      "foo",
+			VaultEngineType: vault.EngineTypeKeyValueV1,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support listing") {
+		t.Fatalf("expected an error listing without support, got %v", err)
+	}
+	if len(results) != 2 || results[0].Action != ActionFailed || results[1].Action != ActionCreated {
+		t.Fatalf("only the list mapping should have failed: %+v", results)
+	}
+}